@@ -1,6 +1,7 @@
 package Benchmark
 
 import (
+	"errors"
 	"io"
 	"log"
 	"strconv"
@@ -23,7 +24,7 @@ func (b *Benchmark) benchRead(records int, seed int) time.Duration {
 
 	for {
 		_, err := db.Read()
-		if err == io.EOF {
+		if errors.Is(err, io.EOF) {
 			break
 		}
 		counter++
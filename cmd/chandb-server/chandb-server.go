@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"github.com/theorx/ChanDB/pkg/Redis"
+	"github.com/theorx/ChanDB/pkg/Server"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	Dir         = flag.String("dir", "data", "Directory the queues are stored in")
+	Listen      = flag.String("listen", ":8080", "Address the HTTP server listens on")
+	MaxWait     = flag.Duration("max-wait", 30*time.Second, "Longest time a dequeue request waits for a record")
+	RedisListen = flag.String("redis-listen", "", "Address the Redis protocol server listens on, disabled when empty")
+	LogLevel    = flag.String("log-level", "info", "Lowest level of the logged messages: debug, info, warn or error")
+)
+
+func main() {
+	flag.Parse()
+
+	level, ok := parseLevel(*LogLevel)
+	if ok == false {
+		log.Fatalf("invalid log level '%s'", *LogLevel)
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	registry, err := ChanDB.CreateRegistry(*Dir, ChanDB.WithLogger(ChanDB.CreateStdLogger(logger, level)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := &http.Server{
+		Addr:    *Listen,
+		Handler: Server.CreateServer(registry, Server.Settings{MaxWait: *MaxWait}),
+	}
+
+	var redisServer *Redis.Server
+	if *RedisListen != "" {
+		redisServer = Redis.CreateServer(registry, Redis.Settings{})
+		go func() {
+			err := redisServer.ListenAndServe(*RedisListen)
+			if err != Redis.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+		logger.Printf("redis protocol listening on %s", *RedisListen)
+	}
+
+	stopped := make(chan bool)
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+
+		//the dequeue requests still waiting for a record after the timeout are answered when the queues are closed
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		if redisServer != nil {
+			redisServer.Close()
+		}
+		close(stopped)
+	}()
+
+	logger.Printf("listening on %s, queues are stored in %s", *Listen, *Dir)
+
+	err = server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+
+	<-stopped
+
+	err = registry.Close()
+	server.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseLevel(name string) (ChanDB.Level, bool) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return ChanDB.LevelDebug, true
+	case "info":
+		return ChanDB.LevelInfo, true
+	case "warn":
+		return ChanDB.LevelWarn, true
+	case "error":
+		return ChanDB.LevelError, true
+	}
+
+	return 0, false
+}
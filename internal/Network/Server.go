@@ -0,0 +1,135 @@
+package Network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+var ErrServerClosed = errors.New("server is closed")
+
+/**
+Server accepts TCP connections and runs the handler for each of them in its own goroutine, the connection is closed
+when the handler returns. The Redis, Remote and Replication servers are built on it, they only differ in what they
+speak over the connections
+*/
+type Server struct {
+	handler     func(ctx context.Context, conn net.Conn)
+	ctx         context.Context
+	cancel      context.CancelFunc
+	lock        *sync.Mutex
+	listeners   map[net.Listener]bool
+	connections map[net.Conn]bool
+	group       *sync.WaitGroup
+}
+
+/**
+The context given to the handler is done once Close() has been called, the handler has to return then
+*/
+func CreateServer(handler func(ctx context.Context, conn net.Conn)) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Server{
+		handler:     handler,
+		ctx:         ctx,
+		cancel:      cancel,
+		lock:        &sync.Mutex{},
+		listeners:   make(map[net.Listener]bool),
+		connections: make(map[net.Conn]bool),
+		group:       &sync.WaitGroup{},
+	}
+}
+
+func (s *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+/**
+Returns ErrServerClosed once Close() has been called, or the error of the listener when it fails on its own. The
+listener is closed by Close()
+*/
+func (s *Server) Serve(listener net.Listener) error {
+	s.lock.Lock()
+	if s.ctx.Err() != nil {
+		s.lock.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listeners[listener] = true
+	s.lock.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return ErrServerClosed
+			}
+
+			s.lock.Lock()
+			delete(s.listeners, listener)
+			s.lock.Unlock()
+			return err
+		}
+
+		s.serveConnection(conn)
+	}
+}
+
+func (s *Server) serveConnection(conn net.Conn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.ctx.Err() != nil {
+		conn.Close()
+		return
+	}
+
+	s.connections[conn] = true
+	s.group.Add(1)
+
+	go func() {
+		defer s.group.Done()
+		defer conn.Close()
+		s.handler(s.ctx, conn)
+
+		s.lock.Lock()
+		delete(s.connections, conn)
+		s.lock.Unlock()
+	}()
+}
+
+/**
+Closes the listeners and the connections and waits until the handlers have returned, returns the first error of
+closing the listeners
+*/
+func (s *Server) Close() error {
+	s.lock.Lock()
+	s.cancel()
+
+	errs := make([]error, 0)
+	for listener := range s.listeners {
+		err := listener.Close()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for conn := range s.connections {
+		conn.Close()
+	}
+	s.listeners = make(map[net.Listener]bool)
+	s.lock.Unlock()
+
+	s.group.Wait()
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
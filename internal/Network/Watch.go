@@ -0,0 +1,43 @@
+package Network
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"time"
+)
+
+/**
+Context for waiting on behalf of a client that sends nothing until it has been answered, such as a blocking pop or
+a long read. It is done when the timeout passes, when ctx is done or when the client disconnects, a timeout of 0
+does not limit the wait. The client is watched by reading the reader of the connection, a byte the client sends
+stays buffered in the reader.
+
+The returned function stops the watching and has to be called before the reader is used again
+*/
+func WatchDisconnect(ctx context.Context, timeout time.Duration, conn net.Conn, reader *bufio.Reader) (context.Context, func()) {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	watching := make(chan bool)
+	go func() {
+		defer close(watching)
+
+		_, err := reader.Peek(1)
+		if err != nil {
+			cancel()
+		}
+	}()
+
+	return ctx, func() {
+		cancel()
+		//a deadline in the past makes the pending Peek return
+		conn.SetReadDeadline(time.Now())
+		<-watching
+		conn.SetReadDeadline(time.Time{})
+	}
+}
@@ -0,0 +1,61 @@
+package Protocol
+
+import (
+	"errors"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+)
+
+//the errors that keep their identity over the connection, errors.Is() works on the client side
+var statusErrors = []struct {
+	status byte
+	err    error
+}{
+	{StatusEmpty, ChanDB.ErrEmpty},
+	{StatusClosed, ChanDB.ErrClosed},
+	{StatusQueueFull, ChanDB.ErrQueueFull},
+	{StatusReadOnly, ChanDB.ErrReadOnly},
+	{StatusInvalidRecord, ChanDB.ErrInvalidRecord},
+}
+
+/**
+Error returned by the server, Is() matches the ChanDB error it stands for and the message is the message of the
+original error
+*/
+type RemoteError struct {
+	Message string
+	Err     error
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+func (e *RemoteError) Unwrap() error {
+	return e.Err
+}
+
+//status and body of the response for an error returned by the database
+func ErrorResponse(err error) (byte, []byte) {
+	for _, statusError := range statusErrors {
+		if errors.Is(err, statusError.err) {
+			return statusError.status, []byte(err.Error())
+		}
+	}
+
+	return StatusError, []byte(err.Error())
+}
+
+//error for a response that is not StatusOK, ErrEmpty is returned as it is so it can be compared with ==
+func ResponseError(status byte, body []byte) error {
+	if status == StatusEmpty {
+		return ChanDB.ErrEmpty
+	}
+
+	for _, statusError := range statusErrors {
+		if status == statusError.status {
+			return &RemoteError{Message: string(body), Err: statusError.err}
+		}
+	}
+
+	return &RemoteError{Message: string(body)}
+}
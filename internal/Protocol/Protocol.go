@@ -0,0 +1,185 @@
+package Protocol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/**
+The remote protocol exchanges frames over TCP: a one byte code, the length of the body as a 4 byte big endian
+integer and the body. The client sends a request frame with an operation code and the server answers it with a
+response frame with a status code, one request at a time on each connection
+*/
+const (
+	OpPing byte = iota + 1
+	//the body is a list of records, they are written as one batch
+	OpWrite
+	//the body is the time to wait for a record in nanoseconds, the response body is the record
+	OpRead
+	//the response body is the number of records
+	OpLength
+	OpTruncate
+	/**
+	Switches the connection to streaming, the body is the number of records the server may send before more
+	credits are granted. The server answers with StatusOK and sends StatusRecord frames from then on, the client
+	sends OpCredit, OpAck and OpProduce frames
+	*/
+	OpSubscribe
+	//the body is the number of additional records the server may send
+	OpCredit
+	//the body is a list of record ids, the records have been processed and they are removed from the database
+	OpAck
+	//the body is an id chosen by the client followed by a list of records, the response body starts with the id
+	OpProduce
+)
+
+const (
+	StatusOK byte = iota + 1
+	StatusEmpty
+	StatusClosed
+	StatusQueueFull
+	StatusReadOnly
+	StatusInvalidRecord
+	//any other error, the body is the error message
+	StatusError
+	//a record sent to a streaming connection, the body is the id of the record followed by the record
+	StatusRecord
+)
+
+const (
+	headerBytes = 5
+	//largest frame body accepted, larger writes have to be split into several batches
+	MaxFrameBytes = 64 << 20
+)
+
+var ErrFrameTooLarge = errors.New("frame is too large")
+
+func WriteFrame(writer *bufio.Writer, code byte, body []byte) error {
+	if len(body) > MaxFrameBytes {
+		return ErrFrameTooLarge
+	}
+
+	header := [headerBytes]byte{code}
+	binary.BigEndian.PutUint32(header[1:], uint32(len(body)))
+
+	writer.Write(header[:])
+	writer.Write(body)
+
+	return writer.Flush()
+}
+
+func ReadFrame(reader *bufio.Reader) (byte, []byte, error) {
+	header := [headerBytes]byte{}
+	_, err := io.ReadFull(reader, header[:])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > MaxFrameBytes {
+		return 0, nil, ErrFrameTooLarge
+	}
+
+	body := make([]byte, size)
+	_, err = io.ReadFull(reader, body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], body, nil
+}
+
+//each string is prefixed with its length as a 4 byte big endian integer
+func EncodeStrings(values []string) []byte {
+	size := 0
+	for _, value := range values {
+		size += 4 + len(value)
+	}
+
+	body := make([]byte, 0, size)
+	for _, value := range values {
+		body = append(body, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(body[len(body)-4:], uint32(len(value)))
+		body = append(body, value...)
+	}
+
+	return body
+}
+
+func DecodeStrings(body []byte) ([]string, error) {
+	values := make([]string, 0)
+
+	for len(body) > 0 {
+		if len(body) < 4 {
+			return nil, errors.New("truncated string length")
+		}
+
+		size := binary.BigEndian.Uint32(body)
+		body = body[4:]
+
+		if uint32(len(body)) < size {
+			return nil, errors.New("truncated string")
+		}
+
+		values = append(values, string(body[:size]))
+		body = body[size:]
+	}
+
+	return values, nil
+}
+
+func EncodeInt(value int64) []byte {
+	body := make([]byte, 8)
+	binary.BigEndian.PutUint64(body, uint64(value))
+
+	return body
+}
+
+func DecodeInt(body []byte) (int64, error) {
+	if len(body) != 8 {
+		return 0, fmt.Errorf("invalid integer of %d bytes", len(body))
+	}
+
+	return int64(binary.BigEndian.Uint64(body)), nil
+}
+
+func EncodeIDs(ids []uint64) []byte {
+	body := make([]byte, 8*len(ids))
+	for i, id := range ids {
+		binary.BigEndian.PutUint64(body[i*8:], id)
+	}
+
+	return body
+}
+
+func DecodeIDs(body []byte) ([]uint64, error) {
+	if len(body)%8 != 0 {
+		return nil, fmt.Errorf("invalid id list of %d bytes", len(body))
+	}
+
+	ids := make([]uint64, len(body)/8)
+	for i := range ids {
+		ids[i] = binary.BigEndian.Uint64(body[i*8:])
+	}
+
+	return ids, nil
+}
+
+//the body of the frames starting with an id, StatusRecord, OpProduce and its response
+func EncodeWithID(id uint64, body []byte) []byte {
+	framed := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint64(framed, id)
+
+	return append(framed, body...)
+}
+
+func DecodeWithID(body []byte) (uint64, []byte, error) {
+	if len(body) < 8 {
+		return 0, nil, errors.New("missing id")
+	}
+
+	return binary.BigEndian.Uint64(body), body[8:], nil
+}
@@ -0,0 +1,217 @@
+package ChanDB
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+/**
+Stream of records that are restored to the database unless they are acknowledged, for the consumers that receive
+several records before they have processed them. The records that have not been acknowledged when the stream or
+the database is closed are restored to their places in the database, so they are read again before the newer
+records. The records are marked read in the files when they are taken from the database, so the records that have
+not been acknowledged when the process crashes are lost: they are delivered at most once after a crash. While any
+stream is open the database holds one record read ahead for its streams, it is lost in a crash as well
+*/
+type AckStream interface {
+	/* The channel is closed when the stream or the database is closed, a record is read ahead for it */
+	Stream() <-chan Delivery
+	/* Waits for the next record until the context is done, returns ErrClosed after Close(). No record is read ahead for it */
+	Receive(ctx context.Context) (Delivery, error)
+	/* Removes the delivered record from the database, returns ErrClosed after Close(), the record has been restored then */
+	Ack(delivery Delivery) error
+	/* Restores the records that have not been acknowledged */
+	Close() error
+}
+
+//record received from an AckStream
+type Delivery struct {
+	Payload string
+	id      uint64
+}
+
+type ackStream struct {
+	manager *manager
+	out     chan Delivery
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan bool
+	lock    *sync.Mutex
+	//the records that have been read and not acknowledged, nil once the stream is closed
+	held      map[uint64]storedRecord
+	nextID    uint64
+	closeLock *sync.Mutex
+	isOpen    bool
+	//the channel is fed once Stream() has been called
+	streaming bool
+	receivers *sync.WaitGroup
+}
+
+/**
+Opens a stream whose records are removed by acknowledging them. The stream is closed with the database, the
+records that have not been acknowledged are restored before the files are closed
+*/
+func (m *manager) ReadAckStream() AckStream {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	instance := &ackStream{
+		manager:   m,
+		out:       make(chan Delivery),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan bool),
+		lock:      &sync.Mutex{},
+		held:      make(map[uint64]storedRecord),
+		closeLock: &sync.Mutex{},
+		isOpen:    true,
+		receivers: &sync.WaitGroup{},
+	}
+
+	if m.registerStream(instance) == false {
+		instance.isOpen = false
+		close(instance.out)
+		return instance
+	}
+
+	return instance
+}
+
+func (s *ackStream) streamRoutine() {
+	defer close(s.done)
+	defer close(s.out)
+
+	for {
+		record, ok := s.manager.nextStreamRecord(s.ctx)
+		if ok == false {
+			return
+		}
+
+		//the record is held from the moment it has been read, Close() restores it when it is not received
+		id := s.hold(record)
+
+		select {
+		case s.out <- Delivery{Payload: record.payload, id: id}:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *ackStream) Stream() <-chan Delivery {
+	s.closeLock.Lock()
+	defer s.closeLock.Unlock()
+
+	if s.isOpen && s.streaming == false {
+		s.streaming = true
+		go s.streamRoutine()
+	}
+
+	return s.out
+}
+
+func (s *ackStream) Receive(ctx context.Context) (Delivery, error) {
+	s.closeLock.Lock()
+	if s.isOpen == false {
+		s.closeLock.Unlock()
+		return Delivery{}, ErrClosed
+	}
+	//Close() waits for the record to be held before restoring the held records
+	s.receivers.Add(1)
+	s.closeLock.Unlock()
+	defer s.receivers.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	//Close() ends the wait
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	record, ok := s.manager.nextStreamRecord(ctx)
+	if ok == false {
+		if s.ctx.Err() != nil {
+			return Delivery{}, ErrClosed
+		}
+		return Delivery{}, ctx.Err()
+	}
+
+	return Delivery{Payload: record.payload, id: s.hold(record)}, nil
+}
+
+func (s *ackStream) hold(record storedRecord) uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.nextID++
+	s.held[s.nextID] = record
+
+	return s.nextID
+}
+
+func (s *ackStream) Ack(delivery Delivery) error {
+	s.lock.Lock()
+	if s.held == nil {
+		s.lock.Unlock()
+		return ErrClosed
+	}
+
+	record, ok := s.held[delivery.id]
+	delete(s.held, delivery.id)
+	s.lock.Unlock()
+
+	//acknowledged already
+	if ok == false {
+		return nil
+	}
+
+	s.manager.streamDelivered(record)
+
+	return nil
+}
+
+func (s *ackStream) Close() error {
+	s.closeLock.Lock()
+	defer s.closeLock.Unlock()
+	//already closed
+	if s.isOpen == false {
+		return nil
+	}
+
+	s.isOpen = false
+	s.cancel()
+	s.receivers.Wait()
+	if s.streaming {
+		<-s.done
+	} else {
+		close(s.out)
+	}
+	s.manager.streamStopped()
+
+	s.lock.Lock()
+	held := s.held
+	s.held = nil
+	s.lock.Unlock()
+
+	//the records are restored in the order they were read, for the records that are written to the end instead
+	ids := make([]uint64, 0, len(held))
+	for id := range held {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	for _, id := range ids {
+		s.manager.restoreRecord(held[id])
+	}
+
+	s.manager.log.debug("ack stream closed", "restored", len(ids))
+
+	return nil
+}
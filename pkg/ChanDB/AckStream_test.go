@@ -0,0 +1,184 @@
+package ChanDB
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func receiveDelivery(t *testing.T, stream AckStream) Delivery {
+	select {
+	case delivery, ok := <-stream.Stream():
+		if ok == false {
+			t.Fatal("the stream has been closed")
+		}
+		return delivery
+	case <-time.After(5 * time.Second):
+		t.Fatal("no record received")
+	}
+
+	return Delivery{}
+}
+
+func TestAckStreamRestoresUnacknowledgedRecords(t *testing.T) {
+	for _, compactions := range []int{0, 2} {
+		db, cleanup := openTestDatabase(t)
+
+		err := db.WriteBatch(numberedRecords("r", 0, 6))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stream := db.ReadAckStream()
+		deliveries := make([]Delivery, 0)
+		for i := 0; i < 3; i++ {
+			deliveries = append(deliveries, receiveDelivery(t, stream))
+		}
+
+		err = stream.Ack(deliveries[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < compactions; i++ {
+			err = db.Compact()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = stream.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = stream.Ack(deliveries[0]); err != ErrClosed {
+			t.Fatalf("acknowledging after Close returned %v", err)
+		}
+
+		records := readAll(t, db)
+		if reflect.DeepEqual(records, []string{"r0", "r2", "r3", "r4", "r5"}) == false {
+			t.Fatalf("%d compactions: records are %v", compactions, records)
+		}
+
+		cleanup()
+	}
+}
+
+func TestAckStreamClosedWithDatabase(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+	dir := filepath.Dir(db.settings.DBFile)
+
+	err := db.WriteBatch(numberedRecords("r", 0, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := db.ReadAckStream()
+	first := receiveDelivery(t, stream)
+	receiveDelivery(t, stream)
+
+	err = stream.Ack(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+
+	if _, ok := <-stream.Stream(); ok {
+		t.Fatal("the stream is open after the database has been closed")
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	records := readAll(t, reopened)
+	if reflect.DeepEqual(records, numberedRecords("r", 1, 4)) == false {
+		t.Fatalf("records are %v", records)
+	}
+}
+
+func TestAckStreamReceiveDoesNotReadAhead(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	err := db.WriteBatch(numberedRecords("r", 0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := db.ReadAckStream()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	delivery, err := stream.Receive(ctx)
+	if err != nil || delivery.Payload != "r0" {
+		t.Fatalf("received %q, %v", delivery.Payload, err)
+	}
+
+	//only the received record has been taken by the stream
+	time.Sleep(50 * time.Millisecond)
+	if reads := db.Stats().Reads; reads != 1 {
+		t.Fatalf("the stream took %d records for one Receive()", reads)
+	}
+
+	err = stream.Ack(delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stream.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stream.Receive(ctx); errors.Is(err, ErrClosed) == false {
+		t.Fatalf("Receive() after Close() returned %v", err)
+	}
+
+	records := readAll(t, db)
+	if reflect.DeepEqual(records, numberedRecords("r", 1, 3)) == false {
+		t.Fatalf("records are %v", records)
+	}
+}
+
+func TestAckStreamReceiveEndsWithContext(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	stream := db.ReadAckStream()
+	defer stream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := stream.Receive(ctx); errors.Is(err, context.DeadlineExceeded) == false {
+		t.Fatalf("Receive() of an empty database returned %v", err)
+	}
+
+	//the database closing ends the wait
+	received := make(chan error, 1)
+	go func() {
+		_, err := stream.Receive(context.Background())
+		received <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	db.Close()
+
+	select {
+	case err := <-received:
+		if errors.Is(err, ErrClosed) == false {
+			t.Fatalf("Receive() returned %v when the database was closed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Receive() did not return when the database was closed")
+	}
+}
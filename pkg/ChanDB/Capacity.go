@@ -0,0 +1,143 @@
+package ChanDB
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+/**
+OverflowPolicy defines what happens to a write that would take the database over MaxRecords or MaxBytes
+*/
+type OverflowPolicy int
+
+const (
+	//the write fails with ErrQueueFull
+	OverflowReject OverflowPolicy = 0
+	//the write waits until enough records have been read, or until the context of WriteContext() is done
+	OverflowBlock OverflowPolicy = 1
+	//the oldest records are discarded until the new record fits
+	OverflowDropOldest OverflowPolicy = 2
+)
+
+var ErrQueueFull = errors.New("queue is full")
+
+/**
+Number of times each of the overflow policies has been applied
+*/
+type OverflowCounters struct {
+	//writes that failed with ErrQueueFull
+	Rejected int64
+	//writes that had to wait for free space
+	Blocked int64
+	//records discarded to make room for new ones
+	Dropped int64
+}
+
+func (m *manager) OverflowCounters() OverflowCounters {
+	return OverflowCounters{
+		Rejected: atomic.LoadInt64(&m.overflowCounters.Rejected),
+		Blocked:  atomic.LoadInt64(&m.overflowCounters.Blocked),
+		Dropped:  atomic.LoadInt64(&m.overflowCounters.Dropped),
+	}
+}
+
+func (m *manager) hasCapacityLimits() bool {
+	return m.settings.MaxRecords > 0 || m.settings.MaxBytes > 0
+}
+
+/**
+Waits until a record of the given size fits within the capacity limits, the caller must hold the capacityLock.
+The lock is released while waiting and it is held again when the function returns
+*/
+func (m *manager) reserveCapacity(ctx context.Context, recordBytes int64) error {
+	if m.settings.MaxBytes > 0 && recordBytes > m.settings.MaxBytes {
+		//the record would not fit even into an empty database
+		atomic.AddInt64(&m.overflowCounters.Rejected, 1)
+		return ErrQueueFull
+	}
+
+	blocked := false
+
+	for {
+		if m.isOpen() == false {
+			return ErrClosed
+		}
+
+		if m.fitsCapacity(recordBytes) {
+			return nil
+		}
+
+		switch m.settings.OverflowPolicy {
+		case OverflowDropOldest:
+			//dropped records are not counted or reported as reads, they are counted in OverflowCounters.Dropped. They
+			//are not the oldest records anymore, so the age of the oldest record moves on
+			_, err := m.readRecord()
+			if errors.Is(err, ErrEmpty) {
+				//the records that are left are being delivered by streams
+				atomic.AddInt64(&m.overflowCounters.Rejected, 1)
+				return ErrQueueFull
+			}
+
+			if err != nil {
+				return err
+			}
+
+			m.activity.recordRemoved()
+			atomic.AddInt64(&m.overflowCounters.Dropped, 1)
+		case OverflowBlock:
+			if blocked == false {
+				blocked = true
+				atomic.AddInt64(&m.overflowCounters.Blocked, 1)
+			}
+
+			err := m.waitForSpace(ctx)
+			if err != nil {
+				return err
+			}
+		default:
+			atomic.AddInt64(&m.overflowCounters.Rejected, 1)
+			return ErrQueueFull
+		}
+	}
+}
+
+//releases the capacityLock until a record has been read or the context is done
+func (m *manager) waitForSpace(ctx context.Context) error {
+	m.capacityLock.Unlock()
+	defer m.capacityLock.Lock()
+
+	//reads done by streams and by other processes are not signaled, so the limits are polled as well
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.spaceSignal.Channel():
+	case <-time.After(time.Millisecond * 25):
+	}
+
+	return nil
+}
+
+func (m *manager) fitsCapacity(recordBytes int64) bool {
+	if m.operationLock != nil {
+		//loads the number of records and bytes stored by the other processes
+		err := m.sharedOperation(func() error {
+			return nil
+		}, m.mainDB, m.writeDB)
+
+		if err != nil {
+			m.log.warn("failed to load the size of the database in shared mode", "error", err)
+		}
+	}
+
+	if m.settings.MaxRecords > 0 && m.mainDB.length()+m.writeDB.length()+1 > m.settings.MaxRecords {
+		return false
+	}
+
+	if m.settings.MaxBytes > 0 && m.mainDB.storedBytes()+m.writeDB.storedBytes()+recordBytes > m.settings.MaxBytes {
+		return false
+	}
+
+	return true
+}
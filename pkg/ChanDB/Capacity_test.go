@@ -0,0 +1,174 @@
+package ChanDB
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOverflowReject(t *testing.T) {
+	db, cleanup := openTestDatabase(t, WithCapacity(2, 0, OverflowReject))
+	defer cleanup()
+
+	err := db.WriteBatch([]string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Write("c")
+	if errors.Is(err, ErrQueueFull) == false {
+		t.Fatalf("write over the limit returned %v", err)
+	}
+
+	//reading frees the space
+	_, err = db.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Write("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if counters := db.OverflowCounters(); counters != (OverflowCounters{Rejected: 1}) {
+		t.Fatalf("counters are %+v", counters)
+	}
+	if records := readAll(t, db); reflect.DeepEqual(records, []string{"b", "c"}) == false {
+		t.Fatalf("records are %v", records)
+	}
+}
+
+func TestOverflowMaxBytes(t *testing.T) {
+	db, cleanup := openTestDatabase(t, WithCapacity(0, 100, OverflowReject))
+	defer cleanup()
+
+	//a record larger than the limit does not fit into an empty database either
+	err := db.Write(strings.Repeat("x", 101))
+	if errors.Is(err, ErrQueueFull) == false {
+		t.Fatalf("write of a record larger than the limit returned %v", err)
+	}
+
+	written := 0
+	for ; written < 100; written++ {
+		err = db.Write("0123456789")
+		if errors.Is(err, ErrQueueFull) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if written == 0 || written >= 10 {
+		t.Fatalf("%d records of 10 bytes fit within 100 bytes", written)
+	}
+	if rejected := db.OverflowCounters().Rejected; rejected != 2 {
+		t.Fatalf("rejected %d writes, expected 2", rejected)
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	db, cleanup := openTestDatabase(t, WithCapacity(1, 0, OverflowBlock))
+	defer cleanup()
+
+	err := db.Write("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the write gives up once the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = db.WriteContext(ctx, "b")
+	if errors.Is(err, context.DeadlineExceeded) == false {
+		t.Fatalf("blocked write returned %v", err)
+	}
+
+	//the write waits until a record is read
+	written := make(chan error, 1)
+	go func() {
+		written <- db.Write("c")
+	}()
+
+	select {
+	case err := <-written:
+		t.Fatalf("write returned %v before there was space", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	payload, err := db.Read()
+	if err != nil || payload != "a" {
+		t.Fatalf("read %q, %v", payload, err)
+	}
+
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the write did not continue after a record was read")
+	}
+
+	if blocked := db.OverflowCounters().Blocked; blocked != 2 {
+		t.Fatalf("blocked %d writes, expected 2", blocked)
+	}
+	if records := readAll(t, db); reflect.DeepEqual(records, []string{"c"}) == false {
+		t.Fatalf("records are %v", records)
+	}
+}
+
+func TestDropOldestIsNotCountedAsRead(t *testing.T) {
+	db, cleanup := openTestDatabase(t, WithCapacity(3, 0, OverflowDropOldest))
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		err := db.Write(fmt.Sprintf("r%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if dropped := db.OverflowCounters().Dropped; dropped != 7 {
+		t.Fatalf("dropped %d records, expected 7", dropped)
+	}
+
+	if reads := db.Stats().Reads; reads != 0 {
+		t.Fatalf("the dropped records were counted as %d reads", reads)
+	}
+
+	records := readAll(t, db)
+	if reflect.DeepEqual(records, numberedRecords("r", 7, 10)) == false {
+		t.Fatalf("records are %v", records)
+	}
+
+	if reads := db.Stats().Reads; reads != 3 {
+		t.Fatalf("counted %d reads, expected 3", reads)
+	}
+}
+
+func TestDropOldestMovesOldestRecordAge(t *testing.T) {
+	db, cleanup := openTestDatabase(t, WithCapacity(3, 0, OverflowDropOldest))
+	defer cleanup()
+
+	err := db.WriteBatch(numberedRecords("old", 0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the age of the records is kept with a precision of a second
+	time.Sleep(1200 * time.Millisecond)
+
+	err = db.WriteBatch(numberedRecords("new", 0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if age := db.Stats().OldestRecordAge; age >= time.Second {
+		t.Fatalf("the oldest record is %v old, the records older than a second have been dropped", age)
+	}
+}
@@ -0,0 +1,112 @@
+package ChanDB
+
+import (
+	"fmt"
+	"time"
+)
+
+/**
+ChangeListener receives the changes of the contents of a database in the order they were made, it is set with
+SetChangeListener(). Applying the changes in the same order to an empty database gives it the same records. OnChange
+is called synchronously while the database locks are held, so it has to return quickly and it must not call the
+database. The change must not be modified, Records is shared with the caller of the write
+*/
+type ChangeListener interface {
+	OnChange(change Change)
+}
+
+type ChangeKind int
+
+const (
+	//Records have been written to the end of the database
+	ChangeWrite ChangeKind = iota + 1
+	//Count records have been removed from the beginning of the database by reading, streaming or dropping them
+	ChangeConsume
+	//all of the records have been removed
+	ChangeTruncate
+)
+
+type Change struct {
+	//the changes are numbered from 1 in the order they were made, the numbering starts again when the database is opened
+	Sequence uint64
+	Kind     ChangeKind
+	Records  []string
+	Count    int64
+	Time     time.Time
+}
+
+/**
+Starts reporting the changes to the listener, nil stops reporting them. A record is reported as consumed when it
+has been read or delivered by a stream, the records held by the streams are consumed only once they are delivered.
+While the changes are listened to, Read() does not skip the record read ahead for the streams and a record held
+by a stream that is closed is written to the end of the database instead of being restored to its place, it is
+reported as consumed and written again. The changes can not be listened to in shared mode, the other processes do
+not report theirs
+*/
+func (m *manager) SetChangeListener(listener ChangeListener) error {
+	if m.settings.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if m.operationLock != nil {
+		return fmt.Errorf("%w: the changes of a shared database can not be listened to", ErrInvalidSettings)
+	}
+
+	m.readLock.Lock()
+	m.writeLock.Lock()
+	m.changeLock.Lock()
+	defer m.readLock.Unlock()
+	defer m.writeLock.Unlock()
+	defer m.changeLock.Unlock()
+
+	if m.isOpen() == false {
+		return ErrClosed
+	}
+
+	m.changes = listener
+
+	return nil
+}
+
+/**
+Calls visit for every record of the database in the order they are read, including the records held by the
+streams, and returns the sequence of the last change the records include. The records are the contents of the
+database at the time of the call, so the records and the changes after the sequence give the contents of the
+database at any later point. Writing continues while the records are visited, reading waits until they have been
+visited. visit must not call the database
+*/
+func (m *manager) SnapshotRecords(visit func(payload string) error) (uint64, error) {
+	return m.snapshot(visit, func(row string) error {
+		payload, err := m.mainDB.encoding.decode(row)
+		if err != nil {
+			return &CorruptError{File: m.settings.DBFile, Err: err}
+		}
+
+		return visit(payload)
+	})
+}
+
+//callers must hold the changeLock
+func (m *manager) reportChange(change Change) {
+	if m.changes == nil {
+		return
+	}
+
+	m.changeSequence++
+	change.Sequence = m.changeSequence
+	change.Time = time.Now()
+
+	m.changes.OnChange(change)
+}
+
+//callers must hold the readLock, the changeLock is taken only when the changes are listened to
+func (m *manager) reportConsumed(count int64) {
+	if m.changes == nil {
+		return
+	}
+
+	m.changeLock.Lock()
+	defer m.changeLock.Unlock()
+
+	m.reportChange(Change{Kind: ChangeConsume, Count: count})
+}
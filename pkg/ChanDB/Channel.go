@@ -0,0 +1,115 @@
+package ChanDB
+
+import (
+	"context"
+)
+
+//maximum number of records Ingest() writes at once
+const ingestBatchSize = 1000
+
+/**
+Writes everything arriving on the channel to the database until the channel is closed or the context is done.
+The records that are already waiting on the channel are written as one batch. Returns nil once the channel has
+been closed and all of its records are written, ctx.Err() when the context is done, ErrClosed once the database
+is closed and the error of the first failed write otherwise. The records received from the channel before
+returning are always written, unless writing them fails
+*/
+func (m *manager) Ingest(ctx context.Context, in <-chan string) error {
+	batch := make([]string, 0, ingestBatchSize)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.closing:
+			return ErrClosed
+		case payload, ok := <-in:
+			if ok == false {
+				return nil
+			}
+
+			batch = append(batch[:0], payload)
+			closed := false
+
+			//collect the records that are already waiting, without waiting for more
+		collect:
+			for len(batch) < ingestBatchSize {
+				select {
+				case payload, ok := <-in:
+					if ok == false {
+						closed = true
+						break collect
+					}
+					batch = append(batch, payload)
+				default:
+					break collect
+				}
+			}
+
+			err := m.WriteBatchContext(ctx, batch)
+			if err != nil {
+				return err
+			}
+
+			if closed {
+				return nil
+			}
+		}
+	}
+}
+
+/**
+Reads the records from the database and sends them to the channel until the context is done. The records are
+read only as fast as the channel accepts them and a record the channel has not accepted stays in the database.
+Returns ctx.Err(), or ErrClosed once the database is closed. The channel is owned by the caller and it is not closed
+*/
+func (m *manager) Deliver(ctx context.Context, out chan<- string) error {
+	m.streamLock.Lock()
+	if m.isOpen() == false {
+		m.streamLock.Unlock()
+		return ErrClosed
+	}
+	m.deliveries.Add(1)
+	m.streamStarted()
+	m.streamLock.Unlock()
+	defer m.deliveries.Done()
+	defer m.streamStopped()
+
+	//the delivery stops when the context is done or when the database is being closed
+	deliveryCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-m.closing:
+			cancel()
+		case <-deliveryCtx.Done():
+		}
+	}()
+
+	for {
+		record, ok := m.nextStreamRecord(deliveryCtx)
+		if ok == false {
+			break
+		}
+
+		select {
+		case out <- record.payload:
+			m.streamDelivered(record)
+		case <-deliveryCtx.Done():
+			//the receiver did not take the record, it is restored to its place in the database
+			m.restoreRecord(record)
+			return m.deliveryError(ctx)
+		}
+	}
+
+	return m.deliveryError(ctx)
+}
+
+func (m *manager) deliveryError(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return ErrClosed
+}
@@ -0,0 +1,127 @@
+package ChanDB
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func waitForResult(t *testing.T, result chan error) error {
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("the call did not return")
+	}
+
+	return nil
+}
+
+func TestIngest(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	//returns once the channel is closed and its records are written
+	in := make(chan string, 3)
+	in <- "r0"
+	in <- "r1"
+	in <- "r2"
+	close(in)
+
+	err := db.Ingest(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the record received before the context is done is written
+	ctx, cancel := context.WithCancel(context.Background())
+	in = make(chan string)
+	result := make(chan error, 1)
+	go func() {
+		result <- db.Ingest(ctx, in)
+	}()
+
+	in <- "r3"
+	cancel()
+	if err := waitForResult(t, result); errors.Is(err, context.Canceled) == false {
+		t.Fatalf("Ingest() returned %v when the context was canceled", err)
+	}
+
+	if records := readAll(t, db); reflect.DeepEqual(records, numberedRecords("r", 0, 4)) == false {
+		t.Fatalf("records are %v", records)
+	}
+
+	//the database closing ends the ingestion
+	go func() {
+		result <- db.Ingest(context.Background(), make(chan string))
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	db.Close()
+	if err := waitForResult(t, result); errors.Is(err, ErrClosed) == false {
+		t.Fatalf("Ingest() returned %v when the database was closed", err)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	err := db.WriteBatch(numberedRecords("r", 0, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan string)
+	result := make(chan error, 1)
+	go func() {
+		result <- db.Deliver(ctx, out)
+	}()
+
+	for i := 0; i < 2; i++ {
+		if record := <-out; record != numberedRecords("r", i, i+1)[0] {
+			t.Fatalf("delivered %q", record)
+		}
+	}
+
+	//the record that was not received is restored to its place
+	cancel()
+	if err := waitForResult(t, result); errors.Is(err, context.Canceled) == false {
+		t.Fatalf("Deliver() returned %v when the context was canceled", err)
+	}
+	if db.Length() != 3 {
+		t.Fatalf("the database holds %d records after delivering 2 of 5", db.Length())
+	}
+
+	//the database closing ends the delivery, the record it holds is kept
+	go func() {
+		result <- db.Deliver(context.Background(), out)
+	}()
+
+	if record := <-out; record != "r2" {
+		t.Fatalf("delivered %q", record)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForResult(t, result); errors.Is(err, ErrClosed) == false {
+		t.Fatalf("Deliver() returned %v when the database was closed", err)
+	}
+
+	db, err = Open(filepath.Dir(db.settings.DBFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if records := readAll(t, db); reflect.DeepEqual(records, numberedRecords("r", 3, 5)) == false {
+		t.Fatalf("records are %v", records)
+	}
+}
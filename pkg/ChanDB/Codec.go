@@ -0,0 +1,77 @@
+package ChanDB
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+/**
+Codec converts values to the payloads stored in the database and back, the payloads must not contain line breaks
+*/
+type Codec interface {
+	Marshal(v interface{}) (string, error)
+	/* Decodes the payload into v, v has to be a pointer */
+	Unmarshal(payload string, v interface{}) error
+}
+
+/**
+Stores the values as compact JSON
+*/
+type JSONCodec struct{}
+
+func (c JSONCodec) Marshal(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(payload), nil
+}
+
+func (c JSONCodec) Unmarshal(payload string, v interface{}) error {
+	return json.Unmarshal([]byte(payload), v)
+}
+
+/**
+Stores the values encoded with encoding/gob, the gob data is base64 encoded so it never contains a line break.
+Every payload carries the gob type information, so the payloads are larger than the ones of a gob stream
+*/
+type GobCodec struct{}
+
+func (c GobCodec) Marshal(v interface{}) (string, error) {
+	buffer := &bytes.Buffer{}
+
+	err := gob.NewEncoder(buffer).Encode(v)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawStdEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+func (c GobCodec) Unmarshal(payload string, v interface{}) error {
+	data, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil {
+		return err
+	}
+
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+//makes sure a codec does not break the one record per line format of the files
+func marshalPayload(codec Codec, v interface{}) (string, error) {
+	payload, err := codec.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.Contains(payload, "\n") {
+		return "", errors.New("codec produced a payload containing a line break")
+	}
+
+	return payload, nil
+}
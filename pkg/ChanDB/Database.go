@@ -3,10 +3,12 @@ package ChanDB
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"github.com/theorx/ChanDB/internal/Version"
 	"github.com/theorx/ChanDB/pkg/Signal"
 	"io"
 	"os"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,91 +18,206 @@ type database struct {
 	readScanner              *bufio.Scanner
 	signal                   *Signal.Signal
 	fileHandle               *os.File
+	handleLock               *sync.RWMutex
 	writeLock                *sync.Mutex
 	readLock                 *sync.Mutex
-	log                      LogFunction
+	log                      *leveledLog
 	subRoutineSpawnLock      *sync.Mutex
 	header                   *Header
-	readStream               chan string
+	readStream               chan storedRecord
 	storageFile              string
 	dbSize                   int64
 	tokenPosition            int64
 	recordsStored            int64
+	bytesStored              int64
 	syncIntervalMilliseconds int
 	scannerEOF               bool
-	syncQuitSignal           chan bool
+	readOnly                 bool
+	shared                   bool
+	syncScheduler            *scheduler
+	syncJob                  *job
+	durability               Durability
+	committer                *groupCommitter
+	encoding                 *recordEncoding
 	readStreamQuitSignal     chan bool
+	readStreamDone           chan bool
+	//set once a stream has been opened, the read stream is started again after the file has been reloaded
+	streaming bool
+	//the read stream is not started while garbage collection is moving the records
+	streamPaused bool
+	//incremented every time the file is loaded or truncated, the positions of the records read before are not
+	//valid anymore
+	generation int64
+	//unix time in nanoseconds of the last successful sync call
+	lastSync    int64
+	syncLatency *latencyHistogram
+	events      EventListener
+	//number of records the header claimed when the file was loaded, -1 when the header could not be read
+	headerRecords int64
+	//records read for the streams that have not been delivered or restored yet, see heldRecords()
+	heldLock *sync.Mutex
+	held     map[*heldLocation]storedRecord
 }
 
-func createDatabase(dbFile string, syncIntervalMilliseconds int, logFunction LogFunction) (*database, error) {
-	if logFunction == nil {
-		return nil, errors.New("invalid log function given for createDatabase() function")
+/**
+Place of a held record in the file, shared by the copies of the record. Garbage collection moves the held records
+to the new file and updates their places, so they can still be restored in place
+*/
+type heldLocation struct {
+	generation int64
+	position   int64
+}
+
+/**
+Record that has been read from a file, the position is kept so the record can be restored to its place
+in the file when it is not delivered
+*/
+type storedRecord struct {
+	db         *database
+	payload    string
+	position   int64
+	size       int64
+	generation int64
+	//number of times the database had been truncated when the record was received, see manager.truncations
+	truncation int64
+	//set while the record is held for the streams, the generation and the position are taken from it then
+	location *heldLocation
+}
+
+var errRecordMoved = errors.New("the file has been rewritten since the record was read")
+
+func createDatabase(dbFile string, settings *Settings, syncScheduler *scheduler, syncLatency *latencyHistogram) (*database, error) {
+	if settings.Logger == nil {
+		return nil, errors.New("invalid logger given for createDatabase() function")
 	}
 
 	instance := &database{
-		signal:    Signal.CreateSignal(),
-		writeLock: &sync.Mutex{},
-		readLock:  &sync.Mutex{},
-		log: func(v ...interface{}) {
-			params := make([]interface{}, 0)
-			params = append(params, dbFile+" => ")
-			for _, param := range v {
-				params = append(params, param)
-			}
-			logFunction(params...)
-		},
+		signal:                   Signal.CreateSignal(),
+		writeLock:                &sync.Mutex{},
+		readLock:                 &sync.Mutex{},
+		handleLock:               &sync.RWMutex{},
+		log:                      createLeveledLog(settings.Logger).with("file", dbFile),
 		subRoutineSpawnLock:      &sync.Mutex{},
-		readStream:               make(chan string, 0),
+		readStream:               make(chan storedRecord),
 		storageFile:              dbFile,
-		syncIntervalMilliseconds: syncIntervalMilliseconds,
+		syncIntervalMilliseconds: settings.SyncSyscallIntervalMilliseconds,
+		syncScheduler:            syncScheduler,
+		syncLatency:              syncLatency,
+		events:                   settings.EventListener,
+		durability:               settings.Durability,
+		encoding:                 createRecordEncoding(settings),
+		readOnly:                 settings.ReadOnly,
+		shared:                   settings.Shared && settings.ReadOnly == false,
 		header: &Header{
 			Version: Version.Version,
 		},
 		tokenPosition: HeaderBytes,
+		heldLock:      &sync.Mutex{},
+		held:          make(map[*heldLocation]storedRecord),
 	}
 
+	window := time.Millisecond * time.Duration(settings.GroupCommitWindowMilliseconds)
+	instance.committer = createGroupCommitter(window, instance.syncFile)
+
 	return instance, instance.loadDatabase()
 }
 
 func (d *database) resetScanner() {
-	d.readScanner = bufio.NewScanner(d.fileHandle)
-	atomic.StoreInt64(&d.tokenPosition, HeaderBytes)
+	d.seekScanner(HeaderBytes)
+}
+
+/**
+Positions the scanner at the given offset, the scanner only sees the bytes that have been fully written
+at the time of the call. This way a record that is being written concurrently is never read partially
+*/
+func (d *database) seekScanner(position int64) {
+	size := atomic.LoadInt64(&d.dbSize)
+	if size < position {
+		size = position
+	}
+
+	d.readScanner = bufio.NewScanner(io.NewSectionReader(d.fileHandle, position, size-position))
+	atomic.StoreInt64(&d.tokenPosition, position)
 }
 
 func (d *database) loadDatabase() error {
-	d.log("initializing database in the loadDatabase function")
+	d.log.debug("loading database file")
+	//records read from the file before it was loaded can not be restored in place anymore
+	atomic.AddInt64(&d.generation, 1)
+
+	flags := os.O_RDWR | os.O_CREATE
+	if d.readOnly {
+		//read-only instances never create or modify the database files
+		flags = os.O_RDONLY
+	}
 
-	fh, err := os.OpenFile(d.storageFile, os.O_RDWR|os.O_CREATE, 0644)
+	fh, err := os.OpenFile(d.storageFile, flags, 0644)
 	if err != nil {
 		return err
 	}
 
+	d.handleLock.Lock()
 	d.fileHandle = fh
+	d.handleLock.Unlock()
 	d.scannerEOF = false
 
 	err = d.header.Read(d.fileHandle)
+	head := int64(HeaderBytes)
 
+	d.headerRecords = d.header.Records
 	if err != nil {
+		d.headerRecords = -1
+		//a new file has no header yet
+		if info, statErr := fh.Stat(); statErr == nil && info.Size() == 0 {
+			d.headerRecords = 0
+		}
+	}
+
+	//the header is kept up to date only in shared mode, otherwise it is written when the database is closed
+	//and it would not be up to date after a crash
+	if err != nil || d.shared == false {
 		err = d.updateStoredRecords()
 		if err != nil {
 			return err
 		}
+	} else {
+		d.setRecordsStored(d.header.Records)
+		d.setBytesStored(d.header.Bytes)
+		if d.header.Head > head {
+			head = d.header.Head
+		}
 	}
 
-	d.header.Records = d.recordsStored
-	err = d.header.Write(d.fileHandle)
-	if err != nil {
-		return err
+	//the encoding of an empty file is changed to the one given in the settings
+	if d.length() > 0 && d.header.Encoding != d.encoding.name() {
+		d.closeFileHandle()
+		return fmt.Errorf("%w: %s is stored with encoding %q, the settings use %q",
+			ErrEncodingMismatch, d.storageFile, d.header.Encoding, d.encoding.name())
 	}
 
-	d.tokenPosition = HeaderBytes
+	atomic.StoreInt64(&d.tokenPosition, head)
+
+	if d.readOnly == false {
+		d.updateHeader()
+		err = d.header.Write(d.fileHandle)
+		if err != nil {
+			return err
+		}
+	}
 
 	err = d.setDatabaseSize()
 	if err != nil {
 		return err
 	}
 
-	d.spawnSyncRoutine()
+	d.seekScanner(head)
+
+	if d.readOnly == false {
+		d.scheduleSync()
+	}
+
+	//the file has been replaced by garbage collection while the streams were reading it
+	d.resumeReadStream()
 
 	return nil
 }
@@ -121,62 +238,125 @@ func (d *database) setDatabaseSize() error {
 	if err != nil {
 		return err
 	}
-	d.dbSize = statInfo.Size()
+	atomic.StoreInt64(&d.dbSize, statInfo.Size())
 	return nil
 }
 
-func (d *database) spawnSyncRoutine() {
+//fsync is called periodically by the sync scheduler, the database does not have a goroutine of its own for it
+func (d *database) scheduleSync() {
 	d.subRoutineSpawnLock.Lock()
-	if d.syncQuitSignal != nil {
-		d.syncQuitSignal <- true
-	} else {
-		d.syncQuitSignal = make(chan bool, 0)
+	if d.syncJob != nil {
+		d.syncScheduler.remove(d.syncJob)
 	}
-	go d.syncRoutine()
+	d.syncJob = d.syncScheduler.add(time.Millisecond*time.Duration(d.syncIntervalMilliseconds), d.sync)
 	d.subRoutineSpawnLock.Unlock()
 }
 
-func (d *database) syncRoutine() {
-	d.log("Starting sync routine")
-	for {
-		select {
-		case <-d.syncQuitSignal:
-			d.log("Quitting sync routine")
-			return
-		default:
-			err := d.fileHandle.Sync()
-			if err != nil {
-				d.log("Sync error:", err)
-			}
-			time.Sleep(time.Millisecond * time.Duration(d.syncIntervalMilliseconds))
-		}
+func (d *database) unscheduleSync() {
+	d.subRoutineSpawnLock.Lock()
+	if d.syncJob != nil {
+		d.syncScheduler.remove(d.syncJob)
+		d.syncJob = nil
+	}
+	d.subRoutineSpawnLock.Unlock()
+}
+
+func (d *database) sync() {
+	err := d.syncFile()
+	if err != nil {
+		d.log.error("sync failed", "error", err)
+	}
+}
+
+func (d *database) syncFile() error {
+	d.handleLock.RLock()
+	defer d.handleLock.RUnlock()
+
+	start := time.Now()
+	err := d.fileHandle.Sync()
+
+	//close() syncs the file before closing it
+	if errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+
+	if err == nil {
+		d.syncDone(start)
+	} else {
+		d.events.OnSyncError(SyncErrorEvent{File: d.storageFile, Err: err})
+	}
+
+	return err
+}
+
+func (d *database) syncDone(start time.Time) {
+	d.syncLatency.since(start)
+	atomic.StoreInt64(&d.lastSync, time.Now().UnixNano())
+}
+
+//zero time when the file has not been synced yet
+func (d *database) lastSyncTime() time.Time {
+	nanoseconds := atomic.LoadInt64(&d.lastSync)
+	if nanoseconds == 0 {
+		return time.Time{}
 	}
+
+	return time.Unix(0, nanoseconds)
+}
+
+/**
+Waits for the written records to be synced to the disk according to the durability setting. Called after
+the write has finished, so concurrent writes can share a group commit
+*/
+func (d *database) commit() error {
+	switch d.durability {
+	case SyncAlways:
+		return d.syncFile()
+	case GroupCommit:
+		return d.committer.commit()
+	}
+
+	return nil
 }
 
 func (d *database) seekNextRecord() (string, bool) {
 
 	d.handleScannerEOF()
 
+	d.readLock.Lock()
+	defer d.readLock.Unlock()
+
 	position := atomic.LoadInt64(&d.tokenPosition)
-	scanner := d.readScanner
 
-	d.readLock.Lock()
-	for scanner.Scan() {
-		row := scanner.Text()
+	for {
+		scanner := d.readScanner
 
-		if len(row) == 0 && d.dbSize > d.tokenPosition+1 {
-			position += 1
-			continue
+		for scanner.Scan() {
+			row := scanner.Text()
+
+			if len(row) > 0 && row[:1] == " " {
+				atomic.StoreInt64(&d.tokenPosition, position)
+				return row, true
+			}
+			position += int64(len(row) + 1)
 		}
 
-		if row[:1] == " " {
-			d.tokenPosition = position
-			d.readLock.Unlock()
-			return row, true
+		if d.readOnly {
+			//the file is being appended to by another instance
+			err := d.setDatabaseSize()
+			if err != nil {
+				d.log.warn("failed to get the size of a read-only database", "error", err)
+			}
 		}
-		position += int64(len(row) + 1)
+
+		if atomic.LoadInt64(&d.dbSize) <= position {
+			break
+		}
+
+		//the scanner has reached the end of the file as it was when the scanner was created,
+		//continue with a scanner that also sees the records that have been written since
+		d.seekScanner(position)
 	}
-	d.readLock.Unlock()
 
 	atomic.StoreInt64(&d.tokenPosition, position)
 
@@ -187,112 +367,300 @@ func (d *database) handleScannerEOF() {
 	if d.scannerEOF {
 		time.Sleep(time.Millisecond * 25)
 		d.scannerEOF = false
-		//here we will reset the position and create a new scanner
+	}
+}
 
-		seekPosition := d.tokenPosition - 100
+//reads the next record and decodes the payload
+func (d *database) read(discardRecord bool) (string, error) {
+	record, err := d.readStored(discardRecord)
+	return record.payload, err
+}
 
-		if seekPosition < HeaderBytes {
-			seekPosition = HeaderBytes
-		}
-		atomic.StoreInt64(&d.tokenPosition, seekPosition)
+//reads the next record and keeps its position in the file
+func (d *database) readStored(discardRecord bool) (storedRecord, error) {
+	generation := atomic.LoadInt64(&d.generation)
 
-		_, err := d.fileHandle.Seek(seekPosition, io.SeekStart)
+	row, position, err := d.readRowAt(discardRecord)
+	if err != nil {
+		return storedRecord{}, err
+	}
 
-		if err != nil {
-			d.log("Failed to seek to new position after scannerEOF", seekPosition, err)
-		}
+	payload, err := d.encoding.decode(row)
+	if err != nil {
+		return storedRecord{}, &CorruptError{File: d.storageFile, Err: err}
+	}
+
+	return storedRecord{
+		db:         d,
+		payload:    payload,
+		position:   position,
+		size:       int64(len(row) + 2),
+		generation: generation,
+	}, nil
+}
+
+/**
+Reads the next record without marking it as read, the scanner is moved back so the next read returns the same
+record
+*/
+func (d *database) peek() (string, error) {
+	row, position, err := d.readRowAt(false)
+	if err != nil {
+		return "", err
+	}
+
+	d.readLock.Lock()
+	d.seekScanner(position)
+	d.readLock.Unlock()
 
-		d.resetScanner()
+	payload, err := d.encoding.decode(row)
+	if err != nil {
+		return "", &CorruptError{File: d.storageFile, Err: err}
 	}
+
+	return payload, nil
 }
 
-func (d *database) read(discardRecord bool) (string, error) {
+//reads the next record as it is stored in the file
+func (d *database) readRow(discardRecord bool) (string, error) {
+	row, _, err := d.readRowAt(discardRecord)
+	return row, err
+}
+
+//reads the next record as it is stored in the file, the position of the record is returned as well
+func (d *database) readRowAt(discardRecord bool) (string, int64, error) {
 	row, status := d.seekNextRecord()
 
 	if status == false {
 		d.scannerEOF = true
-		return "", io.EOF
+		return "", 0, io.EOF
 	}
 
-	if discardRecord == true {
+	position := atomic.LoadInt64(&d.tokenPosition)
+
+	//read-only instances only move past the record, the record stays in the file for the writer
+	if discardRecord == true && d.readOnly == false {
 
-		_, err := d.fileHandle.WriteAt([]byte("-"), d.tokenPosition)
+		_, err := d.fileHandle.WriteAt([]byte("-"), position)
 		d.decrementRecordsStored()
+		d.addBytesStored(-int64(len(row) + 1))
 		if err != nil {
-			return "", err
+			return "", 0, err
 		}
 	}
 
 	atomic.AddInt64(&d.tokenPosition, int64(len(row)+1))
 
 	if len(row) == 0 {
-		return "", nil
+		return "", position, nil
+	}
+
+	return row[1:], position, nil
+}
+
+/**
+Marks a record that has been read as active again, so the record is read again in its original place. Fails with
+errRecordMoved when the file has been loaded again or truncated since the record was read
+*/
+func (d *database) restore(record storedRecord) error {
+	//the records of read-only instances are never marked as read
+	if d.readOnly {
+		return nil
+	}
+
+	d.readLock.Lock()
+	defer d.readLock.Unlock()
+
+	generation, position := d.placeOf(record)
+	if atomic.LoadInt64(&d.generation) != generation {
+		return errRecordMoved
+	}
+
+	_, err := d.fileHandle.WriteAt([]byte(" "), position)
+	if err != nil {
+		return err
+	}
+
+	d.addBytesStored(record.size)
+	d.incrementRecordsStored()
+
+	//the reads continue from the restored record
+	if position < atomic.LoadInt64(&d.tokenPosition) {
+		d.scannerEOF = false
+		d.seekScanner(position)
 	}
 
-	return row[1:], nil
+	return nil
 }
 
-func (d *database) readStreamRoutine() {
-	d.log("Starting readStreamRoutine() ")
+/**
+Reads the records for the streams until the read stream is shut down. A record that has been read when the
+read stream is shut down is restored to its place in the file
+*/
+func (d *database) readStreamRoutine(quit chan bool, done chan bool) {
+	defer close(done)
+	d.log.debug("read stream started")
+
 	for {
 		select {
-		case <-d.readStreamQuitSignal:
-			d.log("Quitting readStreamRoutine() ")
-			//d.readStreamQuitSignal = nil
+		case <-quit:
+			d.log.debug("read stream stopped")
 			return
 		default:
+		}
 
-			msg, err := d.read(true)
-			if err == io.EOF {
-				//wait for the signal to continue
-				<-d.signal.Channel()
-				continue
+		record, err := d.readStored(true)
+		if err == io.EOF {
+			//wait for the signal to continue
+			select {
+			case <-d.signal.Channel():
+			case <-quit:
 			}
+			continue
+		}
+
+		if err != nil {
+			d.log.error("read stream failed to read a record", "error", err)
+			continue
+		}
 
-			d.readStream <- msg
+		//the record is held until the stream that receives it delivers or restores it
+		record = d.hold(record)
+
+		select {
+		case d.readStream <- record:
+		case <-quit:
+			d.release(record)
+			err = d.restore(record)
+			if err != nil {
+				d.log.error("read stream failed to restore a record, the record is lost", "payload", record.payload, "error", err)
+			}
 		}
 	}
 }
 
-func (d *database) streamReads() <-chan string {
+func (d *database) streamReads() <-chan storedRecord {
 	d.subRoutineSpawnLock.Lock()
-	if d.readStreamQuitSignal == nil {
-		d.readStreamQuitSignal = make(chan bool, 1)
-		go d.readStreamRoutine()
+	d.streaming = true
+	if d.streamPaused == false {
+		d.startReadStream()
 	}
 	d.subRoutineSpawnLock.Unlock()
 
 	return d.readStream
 }
 
+//stops reading the records for the streams until streamReads() is called again
+func (d *database) stopStreamReads() {
+	d.subRoutineSpawnLock.Lock()
+	d.streaming = false
+	d.subRoutineSpawnLock.Unlock()
+
+	d.shutDownReadStream()
+}
+
+//stops the read stream routine until resumeReadStream() is called
+func (d *database) pauseReadStream() {
+	d.shutDownReadStream()
+
+	d.subRoutineSpawnLock.Lock()
+	d.streamPaused = true
+	d.subRoutineSpawnLock.Unlock()
+}
+
+//starts the read stream routine again after it has been shut down, if any streams have been opened
+func (d *database) resumeReadStream() {
+	d.subRoutineSpawnLock.Lock()
+	d.streamPaused = false
+	if d.streaming && d.shared == false {
+		d.startReadStream()
+	}
+	d.subRoutineSpawnLock.Unlock()
+}
+
+//callers must hold the subRoutineSpawnLock
+func (d *database) startReadStream() {
+	if d.readStreamQuitSignal == nil {
+		d.readStreamQuitSignal = make(chan bool)
+		d.readStreamDone = make(chan bool)
+		go d.readStreamRoutine(d.readStreamQuitSignal, d.readStreamDone)
+	}
+}
+
+//encodes the payload and writes it to the end of the file
 func (d *database) write(payload string) error {
+	row, err := d.encoding.encode(payload)
+	if err != nil {
+		return err
+	}
+
+	return d.writeRow(row)
+}
+
+//writes a record that is already encoded
+func (d *database) writeRow(row string) error {
+	if d.readOnly {
+		return ErrReadOnly
+	}
+
 	d.writeLock.Lock()
 
-	num, err := d.fileHandle.WriteAt([]byte(" "+payload+"\n"), d.dbSize)
+	num, err := d.fileHandle.WriteAt([]byte(" "+row+"\n"), atomic.LoadInt64(&d.dbSize))
 
 	if err != nil {
 		d.writeLock.Unlock()
-		d.log("Error occurred when writing bytes with writeAt", err)
+		d.log.error("failed writing a record", "error", err)
 		return err
 	}
 
-	d.dbSize += int64(num)
+	atomic.AddInt64(&d.dbSize, int64(num))
 	d.writeLock.Unlock()
 
+	d.addBytesStored(int64(num))
 	d.incrementRecordsStored()
 	return nil
 }
 
+/**
+Writes a record that has been read but not delivered yet, the record is stored as read so it is skipped until it is
+restored. Returns the position of the record
+*/
+func (d *database) writeReadRow(row string) (int64, error) {
+	if d.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	d.writeLock.Lock()
+	defer d.writeLock.Unlock()
+
+	position := atomic.LoadInt64(&d.dbSize)
+	num, err := d.fileHandle.WriteAt([]byte("-"+row+"\n"), position)
+	if err != nil {
+		return 0, err
+	}
+
+	atomic.AddInt64(&d.dbSize, int64(num))
+
+	return position, nil
+}
+
 func (d *database) truncate() error {
+	if d.readOnly {
+		return ErrReadOnly
+	}
+
 	err := d.fileHandle.Truncate(0)
 	if err != nil {
 		return err
 	}
 
+	//the header is written back to the beginning of the file, records are appended after it
+	atomic.StoreInt64(&d.dbSize, HeaderBytes)
+	atomic.AddInt64(&d.generation, 1)
 	d.resetScanner()
-	d.dbSize = 0
-	d.recordsStored = 0
-	d.header.Records = 0
+	d.setRecordsStored(0)
+	d.setBytesStored(0)
+	d.updateHeader()
 	//update the header after truncate
 
 	return d.header.Write(d.fileHandle)
@@ -305,50 +673,77 @@ and all of the routines using any of the data that is temporarily read from the
 all of it back before database file handles are closed
 */
 func (d *database) close() error {
+	//the record held by the read stream routine is restored before the file is closed
+	d.shutDownReadStream()
+
 	d.readLock.Lock()
 	defer d.readLock.Unlock()
-	//write the records back from the readStream when shutting down
-	d.shutDownReadStream()
 
 	if d.fileHandle == nil {
 		return nil
 	}
-	defer d.fileHandle.Close()
+	defer d.closeFileHandle()
+
+	if d.readOnly {
+		return nil
+	}
 
 	//update the number of records stored in the header
-	d.header.Records = d.recordsStored
+	d.updateHeader()
 	err := d.header.Write(d.fileHandle)
 
 	if err != nil {
 		return err
 	}
 
-	d.syncQuitSignal <- true
-	d.syncQuitSignal = nil
+	d.unscheduleSync()
 
-	return d.fileHandle.Sync()
+	start := time.Now()
+	err = d.fileHandle.Sync()
+	if err == nil {
+		d.syncDone(start)
+	}
+
+	return err
 }
 
-func (d *database) shutDownReadStream() {
-	d.subRoutineSpawnLock.Lock()
-	if len(d.readStreamQuitSignal) == 0 && d.readStreamQuitSignal != nil {
-		d.readStreamQuitSignal <- true
+/**
+Updates the header with the number of records stored, in shared mode the read position and the number of
+bytes stored are stored as well
+*/
+func (d *database) updateHeader() {
+	d.header.Records = d.length()
+	d.header.Head = 0
+	d.header.Bytes = 0
+	d.header.Encoding = d.encoding.name()
+
+	if d.shared {
+		d.header.Head = atomic.LoadInt64(&d.tokenPosition)
+		d.header.Bytes = d.storedBytes()
 	}
+}
 
-	for {
-		select {
-		case row, _ := <-d.readStream:
-			err := d.write(row)
-			d.log("Got a record from shutDOwnReadStream() - writing it back", row)
-			if err != nil {
-				d.log("database.close() failed to write back a records from readStream", row, err)
-			}
-		default:
-			d.subRoutineSpawnLock.Unlock()
-			return
+func (d *database) closeFileHandle() error {
+	d.handleLock.Lock()
+	defer d.handleLock.Unlock()
 
-		}
+	return d.fileHandle.Close()
+}
+
+/**
+Stops the read stream routine, the streams keep receiving from the same channel once the routine is started again
+*/
+func (d *database) shutDownReadStream() {
+	d.subRoutineSpawnLock.Lock()
+	defer d.subRoutineSpawnLock.Unlock()
+
+	if d.readStreamQuitSignal == nil {
+		return
 	}
+
+	close(d.readStreamQuitSignal)
+	<-d.readStreamDone
+	d.readStreamQuitSignal = nil
 }
 
 func (d *database) length() int64 {
@@ -368,6 +763,34 @@ func (d *database) setRecordsStored(count int64) {
 	atomic.StoreInt64(&d.recordsStored, count)
 }
 
+//number of bytes the active records take up in the file, records marked for deletion are not accounted for
+func (d *database) storedBytes() int64 {
+	return atomic.LoadInt64(&d.bytesStored)
+}
+
+func (d *database) addBytesStored(bytes int64) {
+	atomic.AddInt64(&d.bytesStored, bytes)
+}
+
+func (d *database) setBytesStored(bytes int64) {
+	atomic.StoreInt64(&d.bytesStored, bytes)
+}
+
+//size of the file including the header
+func (d *database) fileSize() int64 {
+	return atomic.LoadInt64(&d.dbSize)
+}
+
+//bytes of the records that are marked for deletion
+func (d *database) deadBytes() int64 {
+	dead := d.fileSize() - HeaderBytes - d.storedBytes()
+	if dead < 0 {
+		return 0
+	}
+
+	return dead
+}
+
 func (d *database) countRecords() error {
 	_, err := d.fileHandle.Seek(HeaderBytes, io.SeekStart)
 
@@ -377,16 +800,128 @@ func (d *database) countRecords() error {
 
 	scanner := bufio.NewScanner(d.fileHandle)
 	records := int64(0)
+	bytes := int64(0)
 
 	for scanner.Scan() {
 		row := scanner.Text()
 
 		if len(row) > 1 && row[:1] == " " {
 			records++
+			bytes += int64(len(row) + 1)
 		}
 	}
 	_, err = d.fileHandle.Seek(HeaderBytes, io.SeekStart)
 	atomic.StoreInt64(&d.recordsStored, records)
+	atomic.StoreInt64(&d.bytesStored, bytes)
 
 	return err
 }
+
+/**
+Calls visit for the active records stored before the given size of the file in the order they are stored, the
+rows are passed without the leading space and they are not decoded. Callers must keep the records from being read
+and restored while they are visited
+*/
+func (d *database) forEachRow(size int64, visit func(row string) error) error {
+	reader := bufio.NewReader(io.NewSectionReader(d.fileHandle, HeaderBytes, size-HeaderBytes))
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if line[:1] != " " {
+			continue
+		}
+
+		err = visit(line[1 : len(line)-1])
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (d *database) hold(record storedRecord) storedRecord {
+	d.heldLock.Lock()
+	defer d.heldLock.Unlock()
+
+	record.location = &heldLocation{generation: record.generation, position: record.position}
+	d.held[record.location] = record
+
+	return record
+}
+
+//called when the record has been delivered or restored
+func (d *database) release(record storedRecord) {
+	d.heldLock.Lock()
+	defer d.heldLock.Unlock()
+
+	delete(d.held, record.location)
+}
+
+//the held records are not restored after the database has been truncated
+func (d *database) releaseAll() {
+	d.heldLock.Lock()
+	defer d.heldLock.Unlock()
+
+	d.held = make(map[*heldLocation]storedRecord)
+}
+
+//returns the held records in the order they are stored
+func (d *database) heldRecords() []storedRecord {
+	d.heldLock.Lock()
+	defer d.heldLock.Unlock()
+
+	records := make([]storedRecord, 0, len(d.held))
+	for location, record := range d.held {
+		record.generation = location.generation
+		record.position = location.position
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].generation != records[j].generation {
+			return records[i].generation < records[j].generation
+		}
+		return records[i].position < records[j].position
+	})
+
+	return records
+}
+
+//returns the generation of the file the record is stored in and its position in the file
+func (d *database) placeOf(record storedRecord) (int64, int64) {
+	if record.location == nil {
+		return record.generation, record.position
+	}
+
+	d.heldLock.Lock()
+	defer d.heldLock.Unlock()
+
+	return record.location.generation, record.location.position
+}
+
+//the held record has been moved to the position of the file that replaced the file it was read from
+func (d *database) relocate(location *heldLocation, position int64) {
+	d.heldLock.Lock()
+	defer d.heldLock.Unlock()
+
+	location.generation = atomic.LoadInt64(&d.generation)
+	location.position = position
+}
+
+//returns a held record as it is stored in the file, the record has to be stored in the current generation
+func (d *database) heldRow(record storedRecord) (string, error) {
+	row := make([]byte, record.size-2)
+
+	_, err := d.fileHandle.ReadAt(row, record.position+1)
+	if err != nil {
+		return "", err
+	}
+
+	return string(row), nil
+}
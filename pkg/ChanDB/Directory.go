@@ -0,0 +1,207 @@
+package ChanDB
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/theorx/ChanDB/internal/Version"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	//version of the directory layout, directories with a different format are not opened
+	directoryFormat  int    = 1
+	metadataFileName string = "chandb.json"
+)
+
+var ErrIncompatibleFormat = errors.New("database directory has an incompatible format")
+
+/**
+Options are applied to the Settings that Open() passes to CreateDatabase(), the file settings are set by Open()
+*/
+type Option func(*Settings)
+
+/**
+Metadata file stored in the database directory, it describes the files the database is stored in
+*/
+type metadata struct {
+	Format        int    `json:"format"`
+	Version       string `json:"version"`
+	DBFile        string `json:"dbFile"`
+	GCFile        string `json:"gcFile"`
+	WriteOnlyFile string `json:"writeOnlyFile"`
+}
+
+func WithSyncInterval(milliseconds int) Option {
+	return func(settings *Settings) {
+		settings.SyncSyscallIntervalMilliseconds = milliseconds
+	}
+}
+
+/**
+Sets when Write() considers the record stored, the window is used by GroupCommit, see Settings.Durability
+*/
+func WithDurability(durability Durability, windowMilliseconds int) Option {
+	return func(settings *Settings) {
+		settings.Durability = durability
+		settings.GroupCommitWindowMilliseconds = windowMilliseconds
+	}
+}
+
+/**
+Limits the number of records and the bytes they take up, 0 means no limit. The policy handles the writes that would
+exceed a limit
+*/
+func WithCapacity(maxRecords int64, maxBytes int64, policy OverflowPolicy) Option {
+	return func(settings *Settings) {
+		settings.MaxRecords = maxRecords
+		settings.MaxBytes = maxBytes
+		settings.OverflowPolicy = policy
+	}
+}
+
+func WithGarbageCollectionInterval(seconds int) Option {
+	return func(settings *Settings) {
+		settings.GarbageCollectionIntervalSeconds = seconds
+	}
+}
+
+func WithLogFunction(logFunction LogFunction) Option {
+	return func(settings *Settings) {
+		settings.LogFunction = logFunction
+	}
+}
+
+func WithLogger(logger Logger) Option {
+	return func(settings *Settings) {
+		settings.Logger = logger
+	}
+}
+
+func WithReadOnly() Option {
+	return func(settings *Settings) {
+		settings.ReadOnly = true
+	}
+}
+
+func WithShared() Option {
+	return func(settings *Settings) {
+		settings.Shared = true
+	}
+}
+
+func WithCompression(compressor Compressor) Option {
+	return func(settings *Settings) {
+		settings.Compression = compressor
+	}
+}
+
+func WithEncryption(keyProvider KeyProvider) Option {
+	return func(settings *Settings) {
+		settings.KeyProvider = keyProvider
+	}
+}
+
+func WithEventListener(listener EventListener) Option {
+	return func(settings *Settings) {
+		settings.EventListener = listener
+	}
+}
+
+/**
+Opens the database stored in the directory, the directory and the database files are created when the directory
+does not exist or is empty. Open() refuses directories that are not empty and do not contain a database, and
+directories created with an incompatible format
+*/
+func Open(dir string, options ...Option) (*manager, error) {
+	return openDirectory(dir, nil, nil, options)
+}
+
+func openDirectory(dir string, syncScheduler *scheduler, gcScheduler *scheduler, options []Option) (*manager, error) {
+	settings := &Settings{}
+	for _, option := range options {
+		option(settings)
+	}
+
+	meta, err := loadMetadata(dir, settings.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.DBFile = filepath.Join(dir, meta.DBFile)
+	settings.GCFile = filepath.Join(dir, meta.GCFile)
+	settings.WriteOnlyFile = filepath.Join(dir, meta.WriteOnlyFile)
+
+	return createManager(settings, syncScheduler, gcScheduler)
+}
+
+func loadMetadata(dir string, readOnly bool) (*metadata, error) {
+	metadataFile := filepath.Join(dir, metadataFileName)
+	contents, err := ioutil.ReadFile(metadataFile)
+
+	if os.IsNotExist(err) && readOnly == false {
+		return createMetadata(dir)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &metadata{}
+	err = json.Unmarshal(contents, meta)
+	if err != nil {
+		return nil, errors.New("failed to parse " + metadataFile + ": " + err.Error())
+	}
+
+	if meta.Format != directoryFormat {
+		return nil, fmt.Errorf("%w: %s has format %d, supported format is %d", ErrIncompatibleFormat, dir, meta.Format, directoryFormat)
+	}
+
+	return meta, nil
+}
+
+func createMetadata(dir string) (*metadata, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	temporaryFile := filepath.Join(dir, metadataFileName+".tmp")
+
+	for _, entry := range entries {
+		//left behind when creating the metadata file was interrupted
+		if entry.Name() == filepath.Base(temporaryFile) {
+			continue
+		}
+
+		return nil, errors.New("directory " + dir + " is not empty and does not contain a database")
+	}
+
+	meta := &metadata{
+		Format:        directoryFormat,
+		Version:       Version.Version,
+		DBFile:        "db.txt",
+		GCFile:        "gc.txt",
+		WriteOnlyFile: "write.txt",
+	}
+
+	contents, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	//the metadata file is written under a temporary name, a directory never contains a partial metadata file
+	err = ioutil.WriteFile(temporaryFile, contents, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, os.Rename(temporaryFile, filepath.Join(dir, metadataFileName))
+}
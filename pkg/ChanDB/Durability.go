@@ -0,0 +1,76 @@
+package ChanDB
+
+import (
+	"sync"
+	"time"
+)
+
+/**
+Durability defines when Write() considers the record to be stored
+*/
+type Durability int
+
+const (
+	//records are synced to the disk every SyncSyscallIntervalMilliseconds, Write() does not wait for it
+	SyncInterval Durability = 0
+	//Write() returns after the record has been synced to the disk
+	SyncAlways Durability = 1
+	//Write() returns after the record has been synced to the disk, concurrent writes share one sync call
+	GroupCommit Durability = 2
+)
+
+/**
+Writes waiting for a group commit join the batch that is currently open. The first write of a batch waits for
+the group commit window and for the sync call of the previous batch, then closes the batch and syncs the file
+for all of the writes that have joined it meanwhile
+*/
+type groupCommitter struct {
+	lock     *sync.Mutex
+	syncLock *sync.Mutex
+	current  *commitBatch
+	window   time.Duration
+	sync     func() error
+}
+
+type commitBatch struct {
+	done chan bool
+	err  error
+}
+
+func createGroupCommitter(window time.Duration, syncFunction func() error) *groupCommitter {
+	return &groupCommitter{
+		lock:     &sync.Mutex{},
+		syncLock: &sync.Mutex{},
+		window:   window,
+		sync:     syncFunction,
+	}
+}
+
+//must be called after the record has been written, returns after a sync call that started after the write
+func (g *groupCommitter) commit() error {
+	g.lock.Lock()
+	batch := g.current
+	leader := batch == nil
+	if leader {
+		batch = &commitBatch{done: make(chan bool)}
+		g.current = batch
+	}
+	g.lock.Unlock()
+
+	if leader {
+		time.Sleep(g.window)
+		g.syncLock.Lock()
+
+		//writes arriving from now on wait for the next batch
+		g.lock.Lock()
+		g.current = nil
+		g.lock.Unlock()
+
+		batch.err = g.sync()
+		g.syncLock.Unlock()
+		close(batch.done)
+	}
+
+	<-batch.done
+	return batch.err
+}
@@ -0,0 +1,45 @@
+package ChanDB
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestGroupCommitSharesSyncCalls(t *testing.T) {
+	//the scheduled sync calls would be counted as well
+	db, cleanup := openTestDatabase(t, WithSyncInterval(60000), WithDurability(GroupCommit, 5))
+	defer cleanup()
+
+	if db.writeDB.durability != GroupCommit || db.writeDB.committer.window.Milliseconds() != 5 {
+		t.Fatalf("opened with durability %d and a window of %s", db.writeDB.durability, db.writeDB.committer.window)
+	}
+
+	writers := 20
+	wg := sync.WaitGroup{}
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- db.Write(fmt.Sprint(i))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//every write has waited for a sync call, the concurrent writes have shared them
+	syncs := db.Latencies().Sync.Count
+	if syncs == 0 || syncs >= int64(writers) {
+		t.Fatalf("%d writes made %d sync calls", writers, syncs)
+	}
+	if db.Length() != int64(writers) {
+		t.Fatalf("length is %d", db.Length())
+	}
+}
@@ -0,0 +1,247 @@
+package ChanDB
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+var ErrEncodingMismatch = errors.New("database files are stored with a different encoding")
+
+//not used by base64, so the uncompressed rows are told apart from the compressed ones
+const uncompressedMarker = "="
+
+/**
+Compressor compresses the records before they are written to the files. The name of the compressor is stored in
+the header of each file, files holding records are refused when the database is opened with a different compressor
+*/
+type Compressor interface {
+	/* Name identifying the format of the compressed records, it has to fit into the file header */
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+/**
+DEFLATE (RFC 1951) compression, Level is one of the compress/flate levels, 0 uses flate.DefaultCompression
+*/
+type DeflateCompressor struct {
+	Level int
+}
+
+func (c DeflateCompressor) Name() string {
+	return "deflate"
+}
+
+func (c DeflateCompressor) Compress(data []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+
+	buffer := &bytes.Buffer{}
+	writer, err := flate.NewWriter(buffer, level)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (c DeflateCompressor) Decompress(data []byte) ([]byte, error) {
+	reader := flate.NewReader(bytes.NewReader(data))
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
+
+/**
+Gzip (RFC 1952) compression, Level is one of the compress/gzip levels, 0 uses gzip.DefaultCompression
+*/
+type GzipCompressor struct {
+	Level int
+}
+
+func (c GzipCompressor) Name() string {
+	return "gzip"
+}
+
+func (c GzipCompressor) Compress(data []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	buffer := &bytes.Buffer{}
+	writer, err := gzip.NewWriterLevel(buffer, level)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (c GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
+
+/**
+Converts the payloads to the rows stored in the files and back. Without a compressor and encryption the payloads
+are stored as they are, otherwise the payloads are compressed, then encrypted and stored base64 encoded so they
+never contain a line break. A record that does not get shorter by compressing it is stored without compressing
+it, the row starts with uncompressedMarker then and the rest of the row is encoded as without a compressor
+*/
+type recordEncoding struct {
+	compressor Compressor
+	encryptor  *encryptor
+}
+
+func createRecordEncoding(settings *Settings) *recordEncoding {
+	encoding := &recordEncoding{
+		compressor: settings.Compression,
+	}
+
+	if settings.KeyProvider != nil {
+		encoding.encryptor = createEncryptor(settings.KeyProvider)
+	}
+
+	return encoding
+}
+
+//name stored in the file headers, empty for the plain text records
+func (e *recordEncoding) name() string {
+	name := ""
+	if e.compressor != nil {
+		name = e.compressor.Name()
+	}
+
+	if e.encryptor != nil && len(name) > 0 {
+		name += "+"
+	}
+
+	if e.encryptor != nil {
+		name += encryptionName
+	}
+
+	return name
+}
+
+func (e *recordEncoding) encode(payload string) (string, error) {
+	if e.compressor == nil && e.encryptor == nil {
+		return payload, nil
+	}
+
+	if e.compressor == nil {
+		return e.seal([]byte(payload))
+	}
+
+	compressed, err := e.compressor.Compress([]byte(payload))
+	if err != nil {
+		return "", err
+	}
+
+	//small records usually grow when they are compressed, encryption adds the same overhead to both of them
+	if e.encryptor != nil {
+		if len(payload) <= len(compressed) {
+			row, err := e.seal([]byte(payload))
+			return uncompressedMarker + row, err
+		}
+
+		return e.seal(compressed)
+	}
+
+	//without encryption the uncompressed records are stored as plain text, which can not hold a line break
+	row, err := e.seal(compressed)
+	if err == nil && strings.Contains(payload, "\n") == false && len(uncompressedMarker)+len(payload) < len(row) {
+		return uncompressedMarker + payload, nil
+	}
+
+	return row, err
+}
+
+func (e *recordEncoding) decode(row string) (string, error) {
+	if e.compressor == nil && e.encryptor == nil {
+		return row, nil
+	}
+
+	compressed := e.compressor != nil
+	if compressed && strings.HasPrefix(row, uncompressedMarker) {
+		row = row[len(uncompressedMarker):]
+		compressed = false
+
+		if e.encryptor == nil {
+			return row, nil
+		}
+	}
+
+	data, err := e.open(row)
+	if err != nil {
+		return "", err
+	}
+
+	if compressed {
+		data, err = e.compressor.Decompress(data)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return string(data), nil
+}
+
+//encrypts the data when encryption is used and encodes it as base64
+func (e *recordEncoding) seal(data []byte) (string, error) {
+	var err error
+
+	if e.encryptor != nil {
+		data, err = e.encryptor.encrypt(data)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+func (e *recordEncoding) open(row string) ([]byte, error) {
+	data, err := base64.RawStdEncoding.DecodeString(row)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.encryptor != nil {
+		return e.encryptor.decrypt(data)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,132 @@
+package ChanDB
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testEncodings() map[string]*recordEncoding {
+	keys := &StaticKeyProvider{Current: "k1", Keys: map[string][]byte{"k1": []byte("0123456789abcdef")}}
+
+	return map[string]*recordEncoding{
+		"plain":           createRecordEncoding(&Settings{}),
+		"deflate":         createRecordEncoding(&Settings{Compression: DeflateCompressor{}}),
+		"gzip":            createRecordEncoding(&Settings{Compression: GzipCompressor{}}),
+		"aes-gcm":         createRecordEncoding(&Settings{KeyProvider: keys}),
+		"deflate+aes-gcm": createRecordEncoding(&Settings{Compression: DeflateCompressor{}, KeyProvider: keys}),
+	}
+}
+
+//records of the shapes usually stored in a queue
+func testPayloads() map[string]string {
+	return map[string]string{
+		"empty":      "",
+		"id":         "42",
+		"small json": `{"id":1842,"type":"order.created","amount":19.99}`,
+		"large json": strings.Repeat(`{"id":1842,"type":"order.created","items":["a","b","c"]},`, 50),
+		"newline":    "first line\nsecond line",
+		"marker":     "=starts with the marker",
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	for encodingName, encoding := range testEncodings() {
+		for payloadName, payload := range testPayloads() {
+			if encoding.compressor == nil && encoding.encryptor == nil && strings.Contains(payload, "\n") {
+				continue
+			}
+
+			row, err := encoding.encode(payload)
+			if err != nil {
+				t.Fatalf("%s, %s: %v", encodingName, payloadName, err)
+			}
+
+			if strings.Contains(row, "\n") {
+				t.Fatalf("%s, %s: the row contains a newline", encodingName, payloadName)
+			}
+
+			decoded, err := encoding.decode(row)
+			if err != nil {
+				t.Fatalf("%s, %s: %v", encodingName, payloadName, err)
+			}
+
+			if decoded != payload {
+				t.Fatalf("%s, %s: decoded %q", encodingName, payloadName, decoded)
+			}
+		}
+	}
+}
+
+func TestCompressionDoesNotGrowRecords(t *testing.T) {
+	encoding := testEncodings()["deflate"]
+
+	for name, payload := range testPayloads() {
+		if strings.Contains(payload, "\n") {
+			continue
+		}
+
+		row, err := encoding.encode(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(row) > len(payload)+len(uncompressedMarker) {
+			t.Fatalf("%s: the row has %d bytes, the record %d bytes", name, len(row), len(payload))
+		}
+	}
+
+	large := testPayloads()["large json"]
+	row, _ := encoding.encode(large)
+	if len(row)*4 > len(large) {
+		t.Fatalf("the large record is not compressed, %d bytes of %d", len(row), len(large))
+	}
+}
+
+//the rows written before the uncompressed records were stored without compressing them
+func TestDecodeCompressedRowsWithoutMarker(t *testing.T) {
+	encoding := testEncodings()["gzip"]
+
+	compressed, err := GzipCompressor{}.Compress([]byte("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := encoding.decode(base64.RawStdEncoding.EncodeToString(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded != "id" {
+		t.Fatalf("decoded %q", decoded)
+	}
+}
+
+/**
+Reports the number of bytes stored per record for each encoding, run with
+go test -run none -bench EncodedSize ./pkg/ChanDB
+*/
+func BenchmarkEncodedSize(b *testing.B) {
+	for payloadName, payload := range testPayloads() {
+		for encodingName, encoding := range testEncodings() {
+			if encoding.compressor == nil && encoding.encryptor == nil && strings.Contains(payload, "\n") {
+				continue
+			}
+
+			b.Run(fmt.Sprintf("%s/%s", payloadName, encodingName), func(b *testing.B) {
+				size := 0
+				for i := 0; i < b.N; i++ {
+					row, err := encoding.encode(payload)
+					if err != nil {
+						b.Fatal(err)
+					}
+					size = len(row)
+				}
+
+				b.ReportMetric(float64(size), "bytes/record")
+				b.ReportMetric(float64(len(payload)), "payload-bytes")
+			})
+		}
+	}
+}
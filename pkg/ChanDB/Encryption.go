@@ -0,0 +1,155 @@
+package ChanDB
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sync"
+)
+
+const encryptionName = "aes-gcm"
+
+var ErrUnknownKey = errors.New("encryption key not found")
+
+/**
+KeyProvider gives the keys for encrypting the records with AES-GCM, the keys have to be 16, 24 or 32 bytes long.
+New records are encrypted with the current key and the id of the key is stored with each record, so the
+records written before rotating the key can be decrypted as long as the provider still returns the old key.
+The key of an id must never change
+*/
+type KeyProvider interface {
+	/* Key used for encrypting new records and its id, the id can be at most 255 bytes long */
+	CurrentKey() (id string, key []byte, err error)
+	/* Key for decrypting the records that were encrypted with the given id */
+	Key(id string) ([]byte, error)
+}
+
+/**
+KeyProvider holding the keys in memory, Current is the id of the key used for encrypting new records
+*/
+type StaticKeyProvider struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+func (p *StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := p.Key(p.Current)
+	return p.Current, key, err
+}
+
+func (p *StaticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.Keys[id]
+	if ok == false {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+/**
+Encrypts the records with AES-GCM, every record is stored as <id length><key id><nonce><ciphertext>, the key id is
+used as additional data. The ciphers are created once per key id
+*/
+type encryptor struct {
+	keyProvider KeyProvider
+	lock        *sync.Mutex
+	ciphers     map[string]cipher.AEAD
+}
+
+func createEncryptor(keyProvider KeyProvider) *encryptor {
+	return &encryptor{
+		keyProvider: keyProvider,
+		lock:        &sync.Mutex{},
+		ciphers:     make(map[string]cipher.AEAD),
+	}
+}
+
+func (e *encryptor) getCipher(id string, key []byte) (cipher.AEAD, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	aead, ok := e.ciphers[id]
+	if ok {
+		return aead, nil
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	e.ciphers[id] = aead
+	return aead, nil
+}
+
+func (e *encryptor) encrypt(data []byte) ([]byte, error) {
+	id, key, err := e.keyProvider.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(id) > 255 {
+		return nil, errors.New("encryption key id is longer than 255 bytes")
+	}
+
+	aead, err := e.getCipher(id, key)
+	if err != nil {
+		return nil, err
+	}
+
+	record := make([]byte, 0, 1+len(id)+aead.NonceSize()+len(data)+aead.Overhead())
+	record = append(record, byte(len(id)))
+	record = append(record, id...)
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	record = append(record, nonce...)
+
+	return aead.Seal(record, nonce, data, []byte(id)), nil
+}
+
+func (e *encryptor) decrypt(record []byte) ([]byte, error) {
+	if len(record) == 0 || len(record) < 1+int(record[0]) {
+		return nil, errors.New("encrypted record is too short")
+	}
+
+	id := string(record[1 : 1+int(record[0])])
+	record = record[1+len(id):]
+
+	aead, ok := e.cachedCipher(id)
+	if ok == false {
+		key, err := e.keyProvider.Key(id)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err = e.getCipher(id, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(record) < aead.NonceSize() {
+		return nil, errors.New("encrypted record is too short")
+	}
+
+	return aead.Open(nil, record[:aead.NonceSize()], record[aead.NonceSize():], []byte(id))
+}
+
+func (e *encryptor) cachedCipher(id string) (cipher.AEAD, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	aead, ok := e.ciphers[id]
+	return aead, ok
+}
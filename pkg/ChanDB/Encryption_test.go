@@ -0,0 +1,136 @@
+package ChanDB
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testKeyProvider() *StaticKeyProvider {
+	return &StaticKeyProvider{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+		},
+	}
+}
+
+//reads the files of the database, the write-only file is empty outside garbage collection
+func storedBytes(t *testing.T, db *manager) []byte {
+	data, err := ioutil.ReadFile(db.settings.DBFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	keys := testKeyProvider()
+	db, cleanup := openTestDatabase(t, WithEncryption(keys))
+	defer cleanup()
+
+	records := []string{"card 4111 1111 1111 1111", "", "line\nbreak", "ünïcödé"}
+	err := db.WriteBatch(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := storedBytes(t, db)
+	for _, record := range records {
+		if record != "" && bytes.Contains(stored, []byte(record)) {
+			t.Fatalf("the file holds the record %q in plain text", record)
+		}
+	}
+
+	//the records are decrypted after reopening the database
+	dir := filepath.Dir(db.settings.DBFile)
+	db.Close()
+	db, err = Open(dir, WithEncryption(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if read := readAll(t, db); reflect.DeepEqual(read, records) == false {
+		t.Fatalf("read %q", read)
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	keys := testKeyProvider()
+	db, cleanup := openTestDatabase(t, WithEncryption(keys))
+	defer cleanup()
+
+	err := db.Write("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys.Keys["k2"] = bytes.Repeat([]byte{2}, 32)
+	keys.Current = "k2"
+
+	err = db.Write("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//garbage collection moves the records without decrypting them
+	err = db.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read := readAll(t, db); reflect.DeepEqual(read, []string{"old", "new"}) == false {
+		t.Fatalf("read %q", read)
+	}
+}
+
+func TestEncryptionWrongKey(t *testing.T) {
+	db, cleanup := openTestDatabase(t, WithEncryption(testKeyProvider()))
+	defer cleanup()
+
+	err := db.WriteBatch([]string{"secret", "other secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Dir(db.settings.DBFile)
+	db.Close()
+
+	//the records can not be read without the key provider
+	_, err = Open(dir)
+	if errors.Is(err, ErrEncodingMismatch) == false {
+		t.Fatalf("opening without the key provider returned %v", err)
+	}
+
+	//a different key under the same id fails the authentication of the record
+	wrong := testKeyProvider()
+	wrong.Keys["k1"] = bytes.Repeat([]byte{9}, 32)
+	db, err = Open(dir, WithEncryption(wrong))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the record that fails decoding is not returned, the next read continues after it
+	payload, err := db.Read()
+	if errors.Is(err, ErrCorrupt) == false {
+		t.Fatalf("read %q, %v with a wrong key", payload, err)
+	}
+	db.Close()
+
+	//a provider without the key of the records
+	db, err = Open(dir, WithEncryption(&StaticKeyProvider{Current: "k2", Keys: map[string][]byte{"k2": bytes.Repeat([]byte{2}, 32)}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	payload, err = db.Read()
+	if errors.Is(err, ErrUnknownKey) == false || errors.Is(err, ErrCorrupt) == false {
+		t.Fatalf("read %q, %v without the key", payload, err)
+	}
+}
@@ -0,0 +1,128 @@
+package ChanDB
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	//returned by the operations of a database that has been closed
+	ErrClosed = errors.New("database is closed")
+	//returned by Read() when there are no records, it wraps io.EOF so errors.Is(err, io.EOF) keeps working
+	ErrEmpty = fmt.Errorf("database is empty: %w", io.EOF)
+	//a record could not be decoded, the errors are *CorruptError values
+	ErrCorrupt = errors.New("corrupt record")
+	//the settings given to CreateDatabase() or Open() are not valid
+	ErrInvalidSettings = errors.New("invalid settings")
+	//the record contains a newline, which can only be stored when the records are compressed or encrypted
+	ErrInvalidRecord = errors.New("invalid record")
+	//the snapshot given to Restore() is not complete or it was not written by Snapshot()
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+/**
+Error of an operation done on one of the database files
+*/
+type FileError struct {
+	File string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.File + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+/**
+Returned when a record read from a file can not be decoded, errors.Is(err, ErrCorrupt) is true and Err holds the
+error of the decoding
+*/
+type CorruptError struct {
+	File string
+	Err  error
+}
+
+func (e *CorruptError) Error() string {
+	return ErrCorrupt.Error() + " in " + e.File + ": " + e.Err.Error()
+}
+
+func (e *CorruptError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CorruptError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+/**
+MultiError holds the errors of an operation done on several files, such as Close() and Truncate(). The errors are
+usually *FileError values telling which file each of them came from. errors.Is() and errors.As() match any of them
+*/
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *MultiError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+Returns nil when all of the errors are nil, otherwise a *MultiError holding the errors that are not nil.
+Nested MultiErrors are flattened
+*/
+func collectErrors(v ...error) error {
+	errs := make([]error, 0)
+
+	for _, err := range v {
+		if multiError, ok := err.(*MultiError); ok {
+			errs = append(errs, multiError.Errors...)
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &MultiError{Errors: errs}
+}
+
+//wraps the error with the name of the file, nil stays nil
+func fileError(file string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &FileError{File: file, Err: err}
+}
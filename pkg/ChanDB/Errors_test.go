@@ -0,0 +1,68 @@
+package ChanDB
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	_, err := db.Read()
+	if errors.Is(err, ErrEmpty) == false || errors.Is(err, io.EOF) == false {
+		t.Fatalf("Read() of an empty database returned %v", err)
+	}
+
+	_, err = db.Peek()
+	if errors.Is(err, ErrEmpty) == false {
+		t.Fatalf("Peek() of an empty database returned %v", err)
+	}
+
+	err = db.Write("a\nb")
+	if errors.Is(err, ErrInvalidRecord) == false {
+		t.Fatalf("Write() of a record with a newline returned %v", err)
+	}
+
+	corrupt := error(&CorruptError{File: "db.txt", Err: os.ErrInvalid})
+	if errors.Is(corrupt, ErrCorrupt) == false || errors.Is(corrupt, os.ErrInvalid) == false {
+		t.Fatalf("%v does not match ErrCorrupt and its cause", corrupt)
+	}
+}
+
+func TestMultiError(t *testing.T) {
+	if err := collectErrors(nil, fileError("db.txt", nil)); err != nil {
+		t.Fatalf("no errors were collected as %v", err)
+	}
+
+	//nested MultiErrors are flattened
+	err := collectErrors(
+		fileError("db.txt", os.ErrPermission),
+		nil,
+		collectErrors(fileError("gc.txt", ErrClosed), errors.New("plain")),
+	)
+
+	multiError, ok := err.(*MultiError)
+	if ok == false || len(multiError.Errors) != 3 {
+		t.Fatalf("collected %#v", err)
+	}
+
+	if message := err.Error(); message != "db.txt: permission denied; gc.txt: database is closed; plain" {
+		t.Fatalf("message is %q", message)
+	}
+
+	if errors.Is(err, os.ErrPermission) == false || errors.Is(err, ErrClosed) == false {
+		t.Fatal("errors.Is() does not match the collected errors")
+	}
+	if errors.Is(err, ErrEmpty) {
+		t.Fatal("errors.Is() matches an error that was not collected")
+	}
+
+	//errors.As() returns the first matching error
+	var fileErr *FileError
+	if errors.As(err, &fileErr) == false || fileErr.File != "db.txt" || fileErr.Err != os.ErrPermission {
+		t.Fatalf("errors.As() returned %#v", fileErr)
+	}
+}
@@ -0,0 +1,108 @@
+package ChanDB
+
+import "time"
+
+/**
+EventListener receives the events of a database, it is set with Settings.EventListener. The callbacks are called
+synchronously by the goroutine doing the operation, some of them while the database locks are held, so they have
+to return quickly and they must not call the database. Embed NopEventListener to implement only some of them
+*/
+type EventListener interface {
+	OnWrite(event WriteEvent)
+	OnRead(event ReadEvent)
+	OnSyncError(event SyncErrorEvent)
+	OnGCStart(event GCStartEvent)
+	OnGCEnd(event GCEndEvent)
+	OnStreamOpen(event StreamEvent)
+	OnStreamClose(event StreamEvent)
+	OnRecovery(event RecoveryEvent)
+}
+
+/**
+Called for every Write(), WriteBatch() and their context variants, Records is the number of records written
+before the write returned, Err is the error it returned
+*/
+type WriteEvent struct {
+	Records  int
+	Duration time.Duration
+	Err      error
+}
+
+/**
+Called for every record read by Read() or delivered by a stream or Deliver(), and for the reads that failed for
+another reason than the database being empty. Duration is the duration of Read(), it is 0 for the streams
+*/
+type ReadEvent struct {
+	Bytes    int
+	Duration time.Duration
+	Stream   bool
+	Err      error
+}
+
+type SyncErrorEvent struct {
+	File string
+	Err  error
+}
+
+type GCStartEvent struct {
+	//active records when garbage collection started
+	Records int64
+	//bytes of the read records that garbage collection is going to remove
+	DeadBytes int64
+}
+
+type GCEndEvent struct {
+	Duration time.Duration
+	//bytes removed from the database file, approximately
+	ReclaimedBytes int64
+	Err            error
+}
+
+/**
+Called when a stream, a TypedStream or Deliver() starts or stops receiving records, Streams is the number of
+streams receiving records after the change
+*/
+type StreamEvent struct {
+	Streams int64
+}
+
+/**
+Called when a database file is opened and its header does not match the records found in the file, the
+database was not closed properly and the number of records has been counted from the file. HeaderRecords
+is -1 when the header could not be read
+*/
+type RecoveryEvent struct {
+	File          string
+	HeaderRecords int64
+	Records       int64
+}
+
+/**
+EventListener that ignores all of the events
+*/
+type NopEventListener struct {
+}
+
+func (NopEventListener) OnWrite(event WriteEvent)         {}
+func (NopEventListener) OnRead(event ReadEvent)           {}
+func (NopEventListener) OnSyncError(event SyncErrorEvent) {}
+func (NopEventListener) OnGCStart(event GCStartEvent)     {}
+func (NopEventListener) OnGCEnd(event GCEndEvent)         {}
+func (NopEventListener) OnStreamOpen(event StreamEvent)   {}
+func (NopEventListener) OnStreamClose(event StreamEvent)  {}
+func (NopEventListener) OnRecovery(event RecoveryEvent)   {}
+
+//reports the files that were not closed properly, called once the databases have been loaded by init()
+func (m *manager) reportRecovery() {
+	for _, db := range []*database{m.mainDB, m.writeDB} {
+		if db.headerRecords != db.length() {
+			m.log.warn("database file was not closed properly, the records have been counted from the file",
+				"file", db.storageFile, "headerRecords", db.headerRecords, "records", db.length())
+			m.events.OnRecovery(RecoveryEvent{
+				File:          db.storageFile,
+				HeaderRecords: db.headerRecords,
+				Records:       db.length(),
+			})
+		}
+	}
+}
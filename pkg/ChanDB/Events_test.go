@@ -0,0 +1,121 @@
+package ChanDB
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+//keeps the events as strings, without the durations
+type recordingListener struct {
+	lock   *sync.Mutex
+	events []string
+}
+
+func (l *recordingListener) add(event string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.events = append(l.events, event)
+}
+
+func (l *recordingListener) recorded() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	events := l.events
+	l.events = nil
+	return events
+}
+
+func (l *recordingListener) OnWrite(event WriteEvent) {
+	l.add(fmt.Sprintf("write %d %v", event.Records, event.Err != nil))
+}
+
+func (l *recordingListener) OnRead(event ReadEvent) {
+	l.add(fmt.Sprintf("read %d %v", event.Bytes, event.Stream))
+}
+
+func (l *recordingListener) OnSyncError(event SyncErrorEvent) {
+	l.add("sync error " + event.Err.Error())
+}
+
+func (l *recordingListener) OnGCStart(event GCStartEvent) {
+	l.add(fmt.Sprintf("gc start %d %d", event.Records, event.DeadBytes))
+}
+
+func (l *recordingListener) OnGCEnd(event GCEndEvent) {
+	l.add(fmt.Sprintf("gc end %v", event.Err))
+}
+
+func (l *recordingListener) OnStreamOpen(event StreamEvent) {
+	l.add(fmt.Sprintf("stream open %d", event.Streams))
+}
+
+func (l *recordingListener) OnStreamClose(event StreamEvent) {
+	l.add(fmt.Sprintf("stream close %d", event.Streams))
+}
+
+func (l *recordingListener) OnRecovery(event RecoveryEvent) {
+	l.add(fmt.Sprintf("recovery %s %d %d", filepath.Base(event.File), event.HeaderRecords, event.Records))
+}
+
+func expectEvents(t *testing.T, listener *recordingListener, expected ...string) {
+	t.Helper()
+
+	if events := listener.recorded(); reflect.DeepEqual(events, expected) == false {
+		t.Fatalf("events are %q, expected %q", events, expected)
+	}
+}
+
+func TestEventListener(t *testing.T) {
+	listener := &recordingListener{lock: &sync.Mutex{}}
+	db, cleanup := openTestDatabase(t, WithEventListener(listener))
+	defer cleanup()
+	expectEvents(t, listener)
+
+	db.Write("aaaa")
+	db.WriteBatch([]string{"bb", "cc", "dd"})
+	db.Write("invalid\nrecord")
+	expectEvents(t, listener, "write 1 false", "write 3 false", "write 0 true")
+
+	//reading an empty database is not an event
+	db.Read()
+	stream := db.ReadStream()
+	<-stream.Stream()
+	stream.Close()
+	expectEvents(t, listener, "read 4 false", "stream open 1", "read 2 true", "stream close 0")
+
+	//"aaaa" and "bb" have been read, the record read ahead by the stream has been put back
+	err := db.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, listener, "gc start 2 10", "gc end <nil>")
+
+	//a row appended to the file while the database is closed does not match the header
+	dir := filepath.Dir(db.settings.DBFile)
+	db.Close()
+
+	file, err := os.OpenFile(filepath.Join(dir, "db.txt"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(" ee\n")
+	file.Close()
+
+	db, err = Open(dir, WithEventListener(listener))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	expectEvents(t, listener, "recovery db.txt 2 3")
+
+	if _, err := db.Read(); errors.Is(err, ErrEmpty) {
+		t.Fatal("the recovered records can not be read")
+	}
+}
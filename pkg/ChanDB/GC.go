@@ -3,77 +3,188 @@ package ChanDB
 import (
 	"io"
 	"os"
+	"sync/atomic"
 	"time"
 )
 
-func (m *manager) garbageCollectRoutine() {
-	timeElapsed := time.Duration(0)
-	for {
-		select {
-		case <-m.gcQuitSignal:
-
-			return
-		default:
-			timeElapsed += time.Millisecond * 25
-			time.Sleep(time.Millisecond * 25)
-
-			if timeElapsed < time.Second*time.Duration(m.settings.GarbageCollectionIntervalSeconds) {
-				continue
-			}
-			timeElapsed = 0
+//runs on the garbage collection scheduler every GarbageCollectionIntervalSeconds
+func (m *manager) garbageCollectionJob() {
+	m.collectGarbage()
+}
+
+/**
+Runs garbage collection now instead of waiting for the next scheduled run, the records that have been read are
+removed from the files
+*/
+func (m *manager) Compact() error {
+	if m.settings.ReadOnly {
+		return ErrReadOnly
+	}
+
+	return m.collectGarbage()
+}
+
+func (m *manager) collectGarbage() error {
+	m.gcLock.Lock()
+	defer m.gcLock.Unlock()
+
+	if m.isOpen() == false {
+		return ErrClosed
+	}
+
+	start := time.Now()
+	deadBytes := m.mainDB.deadBytes()
+
+	m.events.OnGCStart(GCStartEvent{Records: m.Length(), DeadBytes: deadBytes})
+
+	var err error
+	if m.operationLock != nil {
+		err = m.garbageCollectShared()
+	} else {
+		err = m.garbageCollect()
+		//the records written meanwhile are moved back also when garbage collection fails
+		if err != ErrClosed {
+			err = collectErrors(err, m.writeBackDataToMainDB())
 		}
-		m.garbageCollect()
-		m.writeBackDataToMainDB()
 	}
+
+	duration := time.Since(start)
+	m.gcLatency.observe(duration)
+
+	reclaimed := deadBytes - m.mainDB.deadBytes()
+	if err != nil || reclaimed < 0 {
+		reclaimed = 0
+	}
+
+	m.events.OnGCEnd(GCEndEvent{Duration: duration, ReclaimedBytes: reclaimed, Err: err})
+
+	if err == nil {
+		m.log.info("garbage collection finished", "duration", duration, "reclaimedBytes", reclaimed)
+	}
+
+	return err
 }
 
-func (m *manager) writeBackDataToMainDB() {
+/**
+Moves the records written during garbage collection to the main database. Reading and writing is blocked
+until the records have been moved and the database is switched back to normal mode, so the records keep
+their order relative to the records written before and after garbage collection
+*/
+func (m *manager) writeBackDataToMainDB() error {
+	m.readLock.Lock()
+	m.writeLock.Lock()
+	defer m.readLock.Unlock()
+	defer m.writeLock.Unlock()
+	defer m.setMode(normalMode)
+
+	moved := false
 	for {
-		msg, err := m.writeDB.read(false)
+		msg, err := m.writeDB.readRow(false)
 
 		if err == io.EOF {
 			break
 		}
 
-		err = m.mainDB.write(msg)
+		err = m.mainDB.writeRow(msg)
 		if err != nil {
-			m.log("GC final state error - writeBackDataToMainDB has failed:", err)
-			return
+			m.log.error("garbage collection failed to move the records written meanwhile to the database file", "error", err)
+			return fileError(m.mainDB.storageFile, err)
+		}
+		moved = true
+	}
+
+	//the writes have been acknowledged as synced to the write-only file, they have to be synced to the database
+	//file before they are removed from the write-only file
+	if moved && m.settings.Durability != SyncInterval {
+		err := m.mainDB.syncFile()
+		if err != nil {
+			m.log.error("garbage collection failed to sync the records written meanwhile", "file", m.mainDB.storageFile, "error", err)
+			return fileError(m.mainDB.storageFile, err)
 		}
 	}
 
 	err := m.writeDB.truncate()
 
 	if err != nil {
-		m.log("GC writeDB.truncate has failed:", err)
+		m.log.error("garbage collection failed to truncate the write-only file", "file", m.writeDB.storageFile, "error", err)
 	}
+
+	return fileError(m.writeDB.storageFile, err)
 }
 
-func (m *manager) garbageCollect() {
+func (m *manager) garbageCollect() error {
 	m.readLock.Lock()
 	defer m.readLock.Unlock()
 
+	//the database has been closed while waiting for the lock
+	if m.isOpen() == false {
+		return ErrClosed
+	}
+
 	m.switchToGCMode()
 
 	err := m.gcDB.truncate()
 	if err != nil {
-		m.log("GC failed, gcDB.truncate() error", err)
-		return
+		m.log.error("garbage collection failed to truncate the gc file", "file", m.gcDB.storageFile, "error", err)
+		return fileError(m.gcDB.storageFile, err)
+	}
+
+	//the record held by the read stream routine is restored before the records are moved
+	m.mainDB.pauseReadStream()
+	defer m.mainDB.resumeReadStream()
+
+	held, err := m.moveHeldRecordsToGCDB()
+	if err != nil {
+		m.log.error("garbage collection failed to move the records held by the streams to the gc file", "error", err)
+		return err
 	}
 
 	err = m.moveRecordsToGCDB()
 	if err != nil {
-		m.log("GC failed, moveRecordsToGCDB has failed:", err)
-		return
+		m.log.error("garbage collection failed to move the records to the gc file", "error", err)
+		return err
 	}
 
 	err = m.moveGCDataToMainDB()
 	if err != nil {
-		m.log("GC Failed, moveGCDataToMainDB has failed:", err)
-		return
+		m.log.error("garbage collection failed to replace the database file", "error", err)
+		return err
+	}
+
+	for location, position := range held {
+		m.mainDB.relocate(location, position)
 	}
 
-	m.switchToNormalMode()
+	return nil
+}
+
+/**
+The records held by the streams are older than the active records, they are moved in front of them and stored as
+read, so the streams can restore them to their place in the new file. Returns the new positions of the records
+*/
+func (m *manager) moveHeldRecordsToGCDB() (map[*heldLocation]int64, error) {
+	positions := make(map[*heldLocation]int64)
+
+	for _, record := range m.mainDB.heldRecords() {
+		//the file has been replaced without moving the record, it is written to the end once it is restored
+		if record.generation != atomic.LoadInt64(&m.mainDB.generation) {
+			continue
+		}
+
+		row, err := m.mainDB.heldRow(record)
+		if err != nil {
+			return nil, fileError(m.mainDB.storageFile, err)
+		}
+
+		position, err := m.gcDB.writeReadRow(row)
+		if err != nil {
+			return nil, fileError(m.gcDB.storageFile, err)
+		}
+
+		positions[record.location] = position
+	}
+
+	return positions, nil
 }
 
 func (m *manager) moveGCDataToMainDB() error {
@@ -107,9 +218,10 @@ func (m *manager) moveGCDataToMainDB() error {
 	return nil
 }
 
+//the records are moved as they are stored, so the encoding of the records is preserved
 func (m *manager) moveRecordsToGCDB() error {
 	for {
-		msg, err := m.mainDB.read(false)
+		msg, err := m.mainDB.readRow(false)
 
 		if err == io.EOF {
 			return nil
@@ -119,7 +231,7 @@ func (m *manager) moveRecordsToGCDB() error {
 			return err
 		}
 
-		err = m.gcDB.write(msg)
+		err = m.gcDB.writeRow(msg)
 
 		if err != nil {
 			return err
@@ -127,16 +239,18 @@ func (m *manager) moveRecordsToGCDB() error {
 	}
 }
 
-func (m *manager) switchToNormalMode() {
+func (m *manager) switchToGCMode() {
 	m.writeLock.Lock()
 	defer m.writeLock.Unlock()
 
-	m.mode = normalMode
+	m.setMode(gcMode)
 }
 
-func (m *manager) switchToGCMode() {
-	m.writeLock.Lock()
-	defer m.writeLock.Unlock()
+//callers must hold the writeLock, the mode is read without the lock by Stats()
+func (m *manager) setMode(mode int32) {
+	atomic.StoreInt32(&m.mode, mode)
+}
 
-	m.mode = gcMode
+func (m *manager) getMode() int32 {
+	return atomic.LoadInt32(&m.mode)
 }
@@ -20,10 +20,33 @@ active records in the database, deleted records are not accounted for
 type Header struct {
 	Records int64  `json:"records"`
 	Version string `json:"version"`
+	/**
+	Position of the first record that has not been read yet, only kept up to date in shared mode where
+	the read position has to be shared between the processes
+	*/
+	Head int64 `json:"head,omitempty"`
+	/**
+	Number of bytes the active records take up, only kept up to date in shared mode
+	*/
+	Bytes int64 `json:"bytes,omitempty"`
+	/**
+	Encoding of the records stored in the file, empty when the records are stored as plain text
+	*/
+	Encoding string `json:"encoding,omitempty"`
 }
 
 //update header info in the database file
 func (h *Header) Write(file *os.File) (retError error) {
+	err := h.write(file)
+	if err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+//update header info in the database file without waiting for it to be synced to the disk
+func (h *Header) write(file *os.File) error {
 	header, err := json.Marshal(h)
 	if err != nil {
 		return err
@@ -35,11 +58,7 @@ func (h *Header) Write(file *os.File) (retError error) {
 
 	_, err = file.WriteAt([]byte(" "+string(header)+strings.Repeat("\x00", HeaderBytes-len(header)-2)+"\n"), 0)
 
-	if err != nil {
-		return err
-	}
-
-	return file.Sync()
+	return err
 }
 
 //read the header info
@@ -63,5 +82,12 @@ func (h *Header) Read(file *os.File) (retError error) {
 		return err
 	}
 
-	return json.Unmarshal(bytes.Trim(buffer[1:], "\x00"), h)
+	header := Header{}
+	err = json.Unmarshal(bytes.Trim(buffer[1:], "\x00\n"), &header)
+	if err != nil {
+		return err
+	}
+
+	*h = header
+	return nil
 }
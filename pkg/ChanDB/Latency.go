@@ -0,0 +1,113 @@
+package ChanDB
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+//upper bounds of the buckets the durations of the operations are counted in
+var latencyBuckets = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+/**
+Distribution of the durations of an operation. Counts[i] is the number of operations that took at most Buckets[i]
+and more than Buckets[i-1], the operations that took longer than the last bucket are only included in Count
+*/
+type Histogram struct {
+	Buckets []time.Duration
+	Counts  []int64
+	Count   int64
+	Sum     time.Duration
+}
+
+/**
+Durations of the operations since the database was opened. Write covers the whole Write() call including the wait
+for a sync when the durability setting requires it, Read covers Read() calls and Sync the sync calls of all of
+the database files
+*/
+type Latencies struct {
+	Write             Histogram
+	Read              Histogram
+	Sync              Histogram
+	GarbageCollection Histogram
+}
+
+func (m *manager) Latencies() Latencies {
+	return Latencies{
+		Write:             m.writeLatency.snapshot(),
+		Read:              m.readLatency.snapshot(),
+		Sync:              m.syncLatency.snapshot(),
+		GarbageCollection: m.gcLatency.snapshot(),
+	}
+}
+
+type latencyHistogram struct {
+	counts []int64
+	count  int64
+	sum    int64
+}
+
+func createLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{
+		counts: make([]int64, len(latencyBuckets)),
+	}
+}
+
+func (h *latencyHistogram) observe(duration time.Duration) {
+	bucket := sort.Search(len(latencyBuckets), func(i int) bool {
+		return latencyBuckets[i] >= duration
+	})
+
+	if bucket < len(h.counts) {
+		atomic.AddInt64(&h.counts[bucket], 1)
+	}
+
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(duration))
+}
+
+//measures the time since the given start, used as defer h.since(time.Now())
+func (h *latencyHistogram) since(start time.Time) {
+	h.observe(time.Since(start))
+}
+
+func (h *latencyHistogram) snapshot() Histogram {
+	histogram := Histogram{
+		Buckets: append([]time.Duration(nil), latencyBuckets...),
+		Counts:  make([]int64, len(h.counts)),
+	}
+
+	bucketed := int64(0)
+	for i := range h.counts {
+		histogram.Counts[i] = atomic.LoadInt64(&h.counts[i])
+		bucketed += histogram.Counts[i]
+	}
+
+	histogram.Count = atomic.LoadInt64(&h.count)
+	histogram.Sum = time.Duration(atomic.LoadInt64(&h.sum))
+
+	//an operation observed while the snapshot is taken can be in the buckets without being in the count yet
+	if histogram.Count < bucketed {
+		histogram.Count = bucketed
+	}
+
+	return histogram
+}
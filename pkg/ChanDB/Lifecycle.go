@@ -0,0 +1,61 @@
+package ChanDB
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+/**
+Lifecycle of the manager: initial -> open -> closing -> closed. Operations are only allowed in the open state,
+afterwards they return ErrClosed. The state is checked again once an operation holds the locks, Close() takes
+the same locks, so an operation either finishes before the files are closed or it does not touch them at all
+*/
+const (
+	stateInitial int32 = 0
+	stateOpen    int32 = 1
+	stateClosing int32 = 2
+	stateClosed  int32 = 3
+)
+
+func (m *manager) isOpen() bool {
+	return atomic.LoadInt32(&m.state) == stateOpen
+}
+
+func (m *manager) setState(state int32) {
+	atomic.StoreInt32(&m.state, state)
+}
+
+/**
+Closes the database, the first call closes the files and the calls after it do nothing. When the context is done
+before the database is closed, ctx.Err() is returned and the closing is finished in the background. Calls made
+while the database is being closed wait until it is closed or until their context is done
+*/
+func (m *manager) CloseContext(ctx context.Context) error {
+	if atomic.CompareAndSwapInt32(&m.state, stateOpen, stateClosing) == false {
+		if atomic.LoadInt32(&m.state) == stateInitial {
+			return ErrClosed
+		}
+
+		select {
+		case <-m.closeDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	close(m.closing)
+
+	go func() {
+		m.closeErr = m.close()
+		m.setState(stateClosed)
+		close(m.closeDone)
+	}()
+
+	select {
+	case <-m.closeDone:
+		return m.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,119 @@
+package ChanDB
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOperationsAfterClose(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	err := db.Write("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operations := map[string]func() error{
+		"Write":      func() error { return db.Write("b") },
+		"WriteBatch": func() error { return db.WriteBatch([]string{"b"}) },
+		"Truncate":   db.Truncate,
+		"Compact":    db.Compact,
+		"Read": func() error {
+			_, err := db.Read()
+			return err
+		},
+		"ReadContext": func() error {
+			_, err := db.ReadContext(context.Background())
+			return err
+		},
+		"Peek": func() error {
+			_, err := db.Peek()
+			return err
+		},
+		"Deliver": func() error {
+			return db.Deliver(context.Background(), make(chan string))
+		},
+	}
+
+	for name, operation := range operations {
+		if err := operation(); errors.Is(err, ErrClosed) == false {
+			t.Fatalf("%s() after Close() returned %v", name, err)
+		}
+	}
+
+	if _, ok := <-db.ReadStream().Stream(); ok {
+		t.Fatal("a stream opened after Close() received a record")
+	}
+
+	//closing again does nothing
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := db.CloseContext(ctx); err != nil {
+		t.Fatalf("second close returned %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("third close returned %v", err)
+	}
+}
+
+func TestCloseContextWithOpenStream(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	err := db.WriteBatch(numberedRecords("r", 0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := db.ReadStream()
+	if record := <-stream.Stream(); record != "r0" {
+		t.Fatalf("received %q", record)
+	}
+
+	//the closing can not take the locks while a read is in progress
+	db.readLock.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := db.CloseContext(ctx); errors.Is(err, context.DeadlineExceeded) == false {
+		db.readLock.Unlock()
+		t.Fatalf("CloseContext() returned %v before the database was closed", err)
+	}
+
+	//the operations are refused while the database is being closed
+	if err := db.Write("r3"); errors.Is(err, ErrClosed) == false {
+		db.readLock.Unlock()
+		t.Fatalf("Write() while closing returned %v", err)
+	}
+
+	db.readLock.Unlock()
+
+	//the closing has continued in the background, the calls made meanwhile wait for it
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-stream.Stream(); ok {
+		t.Fatal("the stream received a record after the database was closed")
+	}
+
+	//the record read ahead for the stream has been restored
+	db, err = Open(filepath.Dir(db.settings.DBFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if records := readAll(t, db); reflect.DeepEqual(records, numberedRecords("r", 1, 3)) == false {
+		t.Fatalf("records are %v", records)
+	}
+}
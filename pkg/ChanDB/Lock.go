@@ -0,0 +1,99 @@
+package ChanDB
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+var ErrLocked = errors.New("database is locked, it is already opened by another instance")
+
+/**
+Advisory lock held on a lock file next to the database file for the lifetime of the database instance.
+The lock file is never renamed or truncated, so the lock stays valid while garbage collection is replacing
+the database files
+*/
+type fileLock struct {
+	file *os.File
+}
+
+/**
+Lock that is held while a database opened in shared mode is being read or written, it serializes the
+operations of all of the processes and goroutines that are using the same database files
+*/
+type operationLock struct {
+	lock *sync.Mutex
+	file *os.File
+}
+
+func lockFileName(dbFile string) string {
+	return dbFile + ".lock"
+}
+
+func operationLockFileName(dbFile string) string {
+	return dbFile + ".op.lock"
+}
+
+/**
+Exclusive locks are held by the instances that are the only users of the database files, shared locks are
+held by the instances opened in shared mode. Fails with ErrLocked when the lock is held in the other mode
+*/
+func acquireLock(path string, shared bool) (*fileLock, error) {
+	fh, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = lockFile(fh, shared)
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+
+	return &fileLock{file: fh}, nil
+}
+
+func (l *fileLock) release() error {
+	err := unlockFile(l.file)
+	if err != nil {
+		l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
+
+func createOperationLock(path string) (*operationLock, error) {
+	fh, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &operationLock{
+		lock: &sync.Mutex{},
+		file: fh,
+	}, nil
+}
+
+//blocks until no other goroutine or process is operating on the database files
+func (l *operationLock) acquire() error {
+	l.lock.Lock()
+
+	err := waitLockFile(l.file)
+	if err != nil {
+		l.lock.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (l *operationLock) release() error {
+	defer l.lock.Unlock()
+
+	return unlockFile(l.file)
+}
+
+func (l *operationLock) close() error {
+	return l.file.Close()
+}
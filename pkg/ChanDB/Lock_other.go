@@ -0,0 +1,29 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package ChanDB
+
+import (
+	"errors"
+	"os"
+)
+
+//the platform has no flock(), the database files are not locked, see lockFile
+const fileLocking = false
+
+/**
+The lock file is created but never locked, so opening the same files from another instance does not fail with
+ErrLocked. Open() logs a warning, the callers have to make sure that only one instance writes to the files
+*/
+func lockFile(fh *os.File, shared bool) error {
+	return nil
+}
+
+//without locking the processes can not coordinate their access to the files, shared mode is not available
+func waitLockFile(fh *os.File) error {
+	return errors.New("shared mode is not supported on this platform")
+}
+
+func unlockFile(fh *os.File) error {
+	return nil
+}
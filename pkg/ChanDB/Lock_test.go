@@ -0,0 +1,74 @@
+package ChanDB
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSecondInstanceIsLocked(t *testing.T) {
+	if fileLocking == false {
+		t.Skip("the database files can not be locked on this platform")
+	}
+
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+	dir := filepath.Dir(db.settings.DBFile)
+
+	second, err := Open(dir)
+	if errors.Is(err, ErrLocked) == false {
+		if err == nil {
+			second.Close()
+		}
+		t.Fatalf("second instance opened with %v", err)
+	}
+
+	//the lock is released with the first instance
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Close()
+}
+
+func TestReadOnlyRefusesWrites(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	err := db.WriteBatch([]string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//read-only instances do not take the lock, they are opened alongside the writer
+	reader, err := Open(filepath.Dir(db.settings.DBFile), WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if err := reader.Write("c"); errors.Is(err, ErrReadOnly) == false {
+		t.Fatalf("Write() returned %v", err)
+	}
+	if err := reader.WriteBatch([]string{"c"}); errors.Is(err, ErrReadOnly) == false {
+		t.Fatalf("WriteBatch() returned %v", err)
+	}
+	if err := reader.Truncate(); errors.Is(err, ErrReadOnly) == false {
+		t.Fatalf("Truncate() returned %v", err)
+	}
+
+	//reads return the records without removing them
+	records := readAll(t, reader)
+	if reflect.DeepEqual(records, []string{"a", "b"}) == false {
+		t.Fatalf("read-only instance read %v", records)
+	}
+	if db.Length() != 2 {
+		t.Fatalf("the writer holds %d records after the read-only instance read them", db.Length())
+	}
+}
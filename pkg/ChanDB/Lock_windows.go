@@ -0,0 +1,62 @@
+package ChanDB
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x00000001
+	lockfileExclusiveLock   = 0x00000002
+	//returned by LockFileEx when the lock is held by another handle and LOCKFILE_FAIL_IMMEDIATELY is set
+	errorLockViolation syscall.Errno = 33
+)
+
+//the platform supports locking the database files
+const fileLocking = true
+
+//the first byte of the lock file is locked with LockFileEx, the lock is released when the handle is closed
+func lockFile(fh *os.File, shared bool) error {
+	flags := uint32(lockfileFailImmediately | lockfileExclusiveLock)
+	if shared {
+		flags = lockfileFailImmediately
+	}
+
+	err := lockFileEx(fh, flags)
+	if err == errorLockViolation {
+		return ErrLocked
+	}
+
+	return err
+}
+
+func waitLockFile(fh *os.File) error {
+	return lockFileEx(fh, lockfileExclusiveLock)
+}
+
+func unlockFile(fh *os.File) error {
+	overlapped := syscall.Overlapped{}
+	r, _, err := procUnlockFileEx.Call(fh.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return err
+	}
+
+	return nil
+}
+
+func lockFileEx(fh *os.File, flags uint32) error {
+	overlapped := syscall.Overlapped{}
+	r, _, err := procLockFileEx.Call(fh.Fd(), uintptr(flags), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package ChanDB
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/**
+Severity of a log message, the values are the same as the levels of log/slog
+*/
+type Level int
+
+const (
+	//progress of the internal routines, useful when debugging the database itself
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	//the database recovered from a problem on its own, such as a record written back to the end of the database
+	LevelWarn Level = 4
+	//an operation failed and a record or a garbage collection run may have been lost
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+
+	return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+}
+
+/**
+Logger receives the log messages of the database. The keyvals are alternating keys and values, the keys are
+strings: Log(LevelError, "sync failed", "file", "/data/db.txt", "error", err)
+*/
+type Logger interface {
+	Log(level Level, msg string, keyvals ...interface{})
+}
+
+/**
+Logger writing to a logger of the standard log package, the messages below the given level are dropped. When
+the logger is nil the messages are written to stderr. The messages are formatted as
+
+	WARN failed restoring a record file=/data/db.txt error="file already closed"
+*/
+func CreateStdLogger(logger *log.Logger, level Level) Logger {
+	if logger == nil {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	return &stdLogger{
+		logger: logger,
+		level:  level,
+	}
+}
+
+type stdLogger struct {
+	logger *log.Logger
+	level  Level
+}
+
+func (l *stdLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	if level < l.level {
+		return
+	}
+
+	l.logger.Println(formatMessage(level, msg, keyvals))
+}
+
+//the LogFunction of the settings receives the whole message formatted as one value
+type logFunctionLogger struct {
+	logFunction LogFunction
+}
+
+func (l *logFunctionLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	l.logFunction(formatMessage(level, msg, keyvals))
+}
+
+type nopLogger struct {
+}
+
+func (nopLogger) Log(level Level, msg string, keyvals ...interface{}) {}
+
+//the logger given in the settings, or the LogFunction when no logger is given
+func createSettingsLogger(settings *Settings) Logger {
+	if settings.Logger != nil {
+		return settings.Logger
+	}
+
+	if settings.LogFunction != nil {
+		return &logFunctionLogger{logFunction: settings.LogFunction}
+	}
+
+	return nopLogger{}
+}
+
+func formatMessage(level Level, msg string, keyvals []interface{}) string {
+	builder := &strings.Builder{}
+	builder.WriteString(level.String())
+	builder.WriteString(" ")
+	builder.WriteString(msg)
+
+	for i := 0; i < len(keyvals); i += 2 {
+		builder.WriteString(" ")
+		builder.WriteString(formatValue(keyvals[i]))
+		builder.WriteString("=")
+
+		if i+1 < len(keyvals) {
+			builder.WriteString(formatValue(keyvals[i+1]))
+		} else {
+			builder.WriteString("(MISSING)")
+		}
+	}
+
+	return builder.String()
+}
+
+//values containing spaces or quotes are quoted
+func formatValue(value interface{}) string {
+	text := fmt.Sprint(value)
+
+	if text == "" || strings.ContainsAny(text, " \t\n\"=") {
+		return strconv.Quote(text)
+	}
+
+	return text
+}
+
+/**
+Writes the messages of the database with the fields that are added to every message, such as the file name
+of the database the message is about
+*/
+type leveledLog struct {
+	logger Logger
+	fields []interface{}
+}
+
+func createLeveledLog(logger Logger) *leveledLog {
+	return &leveledLog{logger: logger}
+}
+
+//returns a log that adds the fields to every message
+func (l *leveledLog) with(keyvals ...interface{}) *leveledLog {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+
+	return &leveledLog{logger: l.logger, fields: fields}
+}
+
+func (l *leveledLog) write(level Level, msg string, keyvals []interface{}) {
+	if len(l.fields) == 0 {
+		l.logger.Log(level, msg, keyvals...)
+		return
+	}
+
+	all := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	all = append(all, l.fields...)
+	all = append(all, keyvals...)
+	l.logger.Log(level, msg, all...)
+}
+
+func (l *leveledLog) debug(msg string, keyvals ...interface{}) {
+	l.write(LevelDebug, msg, keyvals)
+}
+
+func (l *leveledLog) info(msg string, keyvals ...interface{}) {
+	l.write(LevelInfo, msg, keyvals)
+}
+
+func (l *leveledLog) warn(msg string, keyvals ...interface{}) {
+	l.write(LevelWarn, msg, keyvals)
+}
+
+func (l *leveledLog) error(msg string, keyvals ...interface{}) {
+	l.write(LevelError, msg, keyvals)
+}
@@ -0,0 +1,33 @@
+//go:build go1.21
+// +build go1.21
+
+package ChanDB
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+/**
+Logger passing the messages to a log/slog handler, the levels map to the slog levels of the same name
+*/
+func CreateSlogLogger(handler slog.Handler) Logger {
+	return &slogLogger{handler: handler}
+}
+
+type slogLogger struct {
+	handler slog.Handler
+}
+
+func (l *slogLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	ctx := context.Background()
+
+	if l.handler.Enabled(ctx, slog.Level(level)) == false {
+		return
+	}
+
+	record := slog.NewRecord(time.Now(), slog.Level(level), msg, 0)
+	record.Add(keyvals...)
+	l.handler.Handle(ctx, record)
+}
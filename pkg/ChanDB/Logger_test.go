@@ -0,0 +1,90 @@
+package ChanDB
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type loggedMessage struct {
+	level   Level
+	msg     string
+	keyvals []interface{}
+}
+
+//keeps the messages of the database
+type recordingLogger struct {
+	lock     *sync.Mutex
+	messages []loggedMessage
+}
+
+func (l *recordingLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.messages = append(l.messages, loggedMessage{level: level, msg: msg, keyvals: keyvals})
+}
+
+func TestStdLoggerLevels(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := CreateStdLogger(log.New(buffer, "", 0), LevelWarn)
+
+	logger.Log(LevelDebug, "dropped")
+	logger.Log(LevelInfo, "dropped")
+	logger.Log(LevelWarn, "failed restoring a record", "file", "/data/db.txt", "error", errors.New("file already closed"))
+	logger.Log(LevelError, "sync failed", "records", 3, "odd")
+	logger.Log(Level(12), "custom level", "empty", "")
+
+	expected := strings.Join([]string{
+		`WARN failed restoring a record file=/data/db.txt error="file already closed"`,
+		`ERROR sync failed records=3 odd=(MISSING)`,
+		`LEVEL(12) custom level empty=""`,
+		"",
+	}, "\n")
+
+	if buffer.String() != expected {
+		t.Fatalf("logged\n%s\nexpected\n%s", buffer.String(), expected)
+	}
+}
+
+func TestDatabaseLogsWithFileName(t *testing.T) {
+	logger := &recordingLogger{lock: &sync.Mutex{}}
+	db, cleanup := openTestDatabase(t, WithLogger(logger))
+	defer cleanup()
+
+	stream := db.ReadStream()
+	stream.Close()
+	db.Close()
+
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+
+	//the messages of the database files tell which file they are about
+	found := false
+	for _, message := range logger.messages {
+		if message.msg == "read stream started" {
+			found = message.level == LevelDebug && len(message.keyvals) >= 2 && message.keyvals[0] == "file" &&
+				message.keyvals[1] == db.settings.DBFile
+		}
+	}
+
+	if found == false {
+		t.Fatalf("logged %+v", logger.messages)
+	}
+}
+
+func TestLogFunctionReceivesFormattedMessages(t *testing.T) {
+	messages := make([]string, 0)
+	logger := createSettingsLogger(&Settings{LogFunction: func(v ...interface{}) {
+		messages = append(messages, v[0].(string))
+	}})
+
+	logger.Log(LevelDebug, "gc started", "records", 10)
+
+	if len(messages) != 1 || messages[0] != "DEBUG gc started records=10" {
+		t.Fatalf("logged %q", messages)
+	}
+}
@@ -1,14 +1,21 @@
 package ChanDB
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/theorx/ChanDB/pkg/Signal"
+	"io"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
-	initialMode int = 0
-	normalMode  int = 1
-	gcMode      int = 2
+	initialMode int32 = 0
+	normalMode  int32 = 1
+	gcMode      int32 = 2
 )
 
 type LogFunction func(v ...interface{})
@@ -39,11 +46,75 @@ type Settings struct {
 	*/
 	GarbageCollectionIntervalSeconds int
 	/**
-	Log function, compatible with log package (log.Println)
+	Log function, compatible with log package (log.Println). Each message is passed as one value holding the level,
+	the message and its fields. Used only when Logger is not set
+
+	Deprecated: use Logger, which receives the level and the fields separately
 	*/
 	LogFunction LogFunction
+	/**
+	Receives the log messages of the database with their level and fields, see CreateStdLogger() and
+	CreateSlogLogger(). By default nothing is logged
+	*/
+	Logger Logger
+	/**
+	Opens the database without modifying any of the files, reads return the records without removing them
+	and writes are rejected with ErrReadOnly. Read-only instances do not take the lock on the database files,
+	so they can be opened alongside an instance that is writing to the same files
+	*/
+	ReadOnly bool
+	/**
+	Opens the database in shared mode, several processes can open the same files in shared mode and write and
+	read them at the same time. The read position and the number of records are stored in the file headers and
+	every operation holds an exclusive lock on <DBFile>.op.lock, so each operation is slower than in the default
+	mode. Shared mode can not be mixed with the default mode on the same files
+	*/
+	Shared bool
+	/**
+	When Write() considers the record stored: SyncInterval (default) leaves syncing to the sync calls made every
+	SyncSyscallIntervalMilliseconds, SyncAlways syncs the file before Write() returns and GroupCommit syncs the
+	file once for all of the writes that arrive within GroupCommitWindowMilliseconds
+	*/
+	Durability Durability
+	/**
+	Time a GroupCommit write waits for other writes to join it before the sync call is made. Writes arriving
+	while the previous sync call is in progress always share the next one, by default there is no extra wait
+	*/
+	GroupCommitWindowMilliseconds int
+	/**
+	Compresses the records stored in the files, DeflateCompressor and GzipCompressor are available from the
+	standard library. By default the records are stored as plain text
+	*/
+	Compression Compressor
+	/**
+	Encrypts the records stored in the files with AES-GCM using the keys given by the KeyProvider, the keys can be
+	rotated without rewriting the files. By default the records are stored unencrypted
+	*/
+	KeyProvider KeyProvider
+	/**
+	Maximum number of active records, 0 means no limit
+	*/
+	MaxRecords int64
+	/**
+	Maximum number of bytes the active records take up in the files, 0 means no limit. Records marked for
+	deletion that are waiting for garbage collection are not counted
+	*/
+	MaxBytes int64
+	/**
+	What happens to writes that would exceed MaxRecords or MaxBytes: OverflowReject (default) fails the write
+	with ErrQueueFull, OverflowBlock waits until the records are read and OverflowDropOldest discards the oldest
+	records. In shared mode the limits are checked by each process separately, so concurrent writes from several
+	processes can exceed them slightly
+	*/
+	OverflowPolicy OverflowPolicy
+	/**
+	Receives the events of the database, such as writes, reads, sync errors and garbage collection runs
+	*/
+	EventListener EventListener
 }
 
+var ErrReadOnly = errors.New("database is opened in read-only mode")
+
 type Database interface {
 	/* Reading will discard the record from the database */
 	Read() (string, error)
@@ -60,130 +131,622 @@ type Database interface {
 }
 
 type manager struct {
-	settings     *Settings
-	readLock     *sync.Mutex
-	writeLock    *sync.Mutex
-	mainDB       *database
-	gcDB         *database
-	writeDB      *database
-	mode         int
-	gcQuitSignal chan bool
-	log          LogFunction
-	streams      []Stream
+	settings       *Settings
+	readLock       *sync.Mutex
+	writeLock      *sync.Mutex
+	mainDB         *database
+	gcDB           *database
+	writeDB        *database
+	mode           int32
+	log            *leveledLog
+	streams        []io.Closer
+	lock           *fileLock
+	operationLock  *operationLock
+	syncScheduler  *scheduler
+	gcScheduler    *scheduler
+	gcJob          *job
+	ownsSchedulers bool
+	closeHook      func()
+	//serializes the writes while the capacity limits are checked
+	capacityLock *sync.Mutex
+	//signaled when records are read from the database
+	spaceSignal      *Signal.Signal
+	overflowCounters OverflowCounters
+	//lifecycle state, see Lifecycle.go
+	state      int32
+	closing    chan bool
+	closeDone  chan bool
+	closeErr   error
+	streamLock *sync.Mutex
+	deliveries *sync.WaitGroup
+	//number of times the database has been truncated
+	truncations int64
+	//counts the operations for Stats()
+	activity      *activity
+	activeStreams int64
+	events        EventListener
+	//notified when records are written or restored, ReadContext() waits for it
+	written *Signal.Broadcast
+	//one garbage collection runs at a time
+	gcLock *sync.Mutex
+	//reports the changes in the order they are made, see Changes.go
+	changeLock     *sync.Mutex
+	changes        ChangeListener
+	changeSequence uint64
+	//durations of the operations for Latencies()
+	writeLatency *latencyHistogram
+	readLatency  *latencyHistogram
+	syncLatency  *latencyHistogram
+	gcLatency    *latencyHistogram
 }
 
 func CreateDatabase(settings *Settings) (*manager, error) {
+	return createManager(settings, nil, nil)
+}
+
+/**
+Schedulers given by the caller are shared with other databases and they are not stopped when the database is
+closed, when they are nil the database creates schedulers of its own
+*/
+func createManager(settings *Settings, syncScheduler *scheduler, gcScheduler *scheduler) (*manager, error) {
 
 	if len(settings.DBFile) == 0 {
-		return nil, errors.New("no DBFile given in Settings")
+		return nil, fmt.Errorf("%w: no DBFile given", ErrInvalidSettings)
 	}
 
 	if len(settings.GCFile) == 0 {
-		return nil, errors.New("no GCFile given in Settings")
+		return nil, fmt.Errorf("%w: no GCFile given", ErrInvalidSettings)
 	}
 
 	if len(settings.WriteOnlyFile) == 0 {
-		return nil, errors.New("no WriteOnlyFile given in Settings")
+		return nil, fmt.Errorf("%w: no WriteOnlyFile given", ErrInvalidSettings)
 	}
 
 	if settings.GarbageCollectionIntervalSeconds < 10 {
 		settings.GarbageCollectionIntervalSeconds = 10
 	}
 
+	if settings.OverflowPolicy < OverflowReject || settings.OverflowPolicy > OverflowDropOldest {
+		return nil, fmt.Errorf("%w: unknown OverflowPolicy %d", ErrInvalidSettings, settings.OverflowPolicy)
+	}
+
 	if settings.SyncSyscallIntervalMilliseconds < 100 {
 		settings.SyncSyscallIntervalMilliseconds = 100
 	}
 
-	if settings.LogFunction == nil {
-		//set the default logging function
-		settings.LogFunction = func(v ...interface{}) {
-		}
+	//the LogFunction is used when no logger is given
+	settings.Logger = createSettingsLogger(settings)
+
+	if settings.EventListener == nil {
+		settings.EventListener = NopEventListener{}
 	}
 
 	mgr := &manager{
-		settings: settings,
-		log:      settings.LogFunction,
+		settings:      settings,
+		log:           createLeveledLog(settings.Logger),
+		events:        settings.EventListener,
+		syncScheduler: syncScheduler,
+		gcScheduler:   gcScheduler,
 	}
 
 	return mgr, mgr.init()
 }
 
-func (m *manager) init() error {
+func (m *manager) init() (err error) {
 	//initialize values
 	m.writeLock = &sync.Mutex{}
 	m.readLock = &sync.Mutex{}
-	m.gcQuitSignal = make(chan bool, 0)
+	m.capacityLock = &sync.Mutex{}
+	m.streamLock = &sync.Mutex{}
+	m.closing = make(chan bool)
+	m.closeDone = make(chan bool)
+	m.deliveries = &sync.WaitGroup{}
+	m.spaceSignal = Signal.CreateSignal()
+	m.written = Signal.CreateBroadcast()
+	m.gcLock = &sync.Mutex{}
+	m.changeLock = &sync.Mutex{}
+	m.writeLatency = createLatencyHistogram()
+	m.readLatency = createLatencyHistogram()
+	m.syncLatency = createLatencyHistogram()
+	m.gcLatency = createLatencyHistogram()
+
+	if m.syncScheduler == nil || m.gcScheduler == nil {
+		m.ownsSchedulers = true
+		m.syncScheduler = createScheduler()
+		m.gcScheduler = createScheduler()
+	}
+
+	defer func() {
+		if err != nil {
+			m.abortInit()
+		}
+	}()
+
+	if m.settings.ReadOnly == false {
+		if fileLocking == false {
+			m.log.warn("the database files can not be locked on this platform, another instance opening them is not refused")
+		}
+
+		m.lock, err = acquireLock(lockFileName(m.settings.DBFile), m.settings.Shared)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err != nil {
+				m.lock.release()
+			}
+		}()
+	}
+
+	if m.settings.ReadOnly == false && m.settings.Shared {
+		m.operationLock, err = createOperationLock(operationLockFileName(m.settings.DBFile))
+		if err != nil {
+			return err
+		}
+
+		//the headers are written while the databases are loaded
+		err = m.operationLock.acquire()
+		if err != nil {
+			return err
+		}
+		defer m.operationLock.release()
+	}
 
 	//todo: optimize the code repetitions for creating the databases
 	//set-up database instances
 
-	instance, err := createDatabase(m.settings.DBFile, m.settings.SyncSyscallIntervalMilliseconds, m.settings.LogFunction)
+	instance, err := createDatabase(m.settings.DBFile, m.settings, m.syncScheduler, m.syncLatency)
 	if err != nil {
 		return err
 	}
 
 	m.mainDB = instance
 
-	instance, err = createDatabase(m.settings.WriteOnlyFile, m.settings.SyncSyscallIntervalMilliseconds, m.settings.LogFunction)
+	instance, err = createDatabase(m.settings.WriteOnlyFile, m.settings, m.syncScheduler, m.syncLatency)
 	if err != nil {
 		return err
 	}
 
 	m.writeDB = instance
 
-	instance, err = createDatabase(m.settings.GCFile, m.settings.SyncSyscallIntervalMilliseconds, m.settings.LogFunction)
+	instance, err = createDatabase(m.settings.GCFile, m.settings, m.syncScheduler, m.syncLatency)
 	if err != nil {
 		return err
 	}
 
 	m.gcDB = instance
+	m.activity = createActivity(m.mainDB.length() + m.writeDB.length())
 
-	go m.garbageCollectRoutine()
+	//read-only instances see the headers of the files that are being written
+	if m.settings.ReadOnly == false {
+		m.reportRecovery()
+	}
+
+	//garbage collection would rewrite the files, read-only instances leave that to the writer
+	if m.settings.ReadOnly == false {
+		interval := time.Second * time.Duration(m.settings.GarbageCollectionIntervalSeconds)
+		m.gcJob = m.gcScheduler.add(interval, m.garbageCollectionJob)
+	}
 
 	//database successfully running
-	m.mode = normalMode
+	m.setMode(normalMode)
+	m.setState(stateOpen)
 
 	return nil
 }
 
-func (m *manager) Write(payload string) (err error) {
+//releases whatever init() has set up before failing
+func (m *manager) abortInit() {
+	for _, db := range []*database{m.mainDB, m.writeDB, m.gcDB} {
+		if db != nil {
+			db.unscheduleSync()
+			db.fileHandle.Close()
+		}
+	}
+
+	if m.operationLock != nil {
+		m.operationLock.close()
+	}
+
+	if m.ownsSchedulers {
+		m.syncScheduler.stop()
+		m.gcScheduler.stop()
+	}
+}
+
+func (m *manager) Write(payload string) error {
+	return m.WriteContext(context.Background(), payload)
+}
+
+/**
+Writes data to the database, the context limits the time spent waiting for free space when the database is full
+and the OverflowPolicy is OverflowBlock
+*/
+func (m *manager) WriteContext(ctx context.Context, payload string) error {
+	return m.WriteBatchContext(ctx, []string{payload})
+}
+
+func (m *manager) WriteBatch(payloads []string) error {
+	return m.WriteBatchContext(context.Background(), payloads)
+}
+
+/**
+Writes the payloads in the given order, without capacity limits the records are written at once and share one sync
+call. When a write fails, the records before it have been written and the ones after it have not
+*/
+func (m *manager) WriteBatchContext(ctx context.Context, payloads []string) error {
+	if m.isOpen() == false {
+		return ErrClosed
+	}
+
+	if m.settings.ReadOnly {
+		return ErrReadOnly
+	}
+
+	start := time.Now()
+	written, err := m.writeBatch(ctx, payloads)
+	duration := time.Since(start)
+
+	m.writeLatency.observe(duration)
+	m.events.OnWrite(WriteEvent{Records: written, Duration: duration, Err: err})
+
+	return err
+}
+
+//returns the number of records written
+func (m *manager) writeBatch(ctx context.Context, payloads []string) (int, error) {
+	//the payloads are encoded up front, the capacity limits apply to the size of the stored records
+	rows := make([]string, len(payloads))
+	for i, payload := range payloads {
+		row, err := m.mainDB.encoding.encode(payload)
+		if err != nil {
+			return 0, err
+		}
+
+		//every record is stored on a line of its own
+		if strings.Contains(row, "\n") {
+			return 0, fmt.Errorf("%w: the record contains a newline, enable compression or encryption to store it",
+				ErrInvalidRecord)
+		}
+		rows[i] = row
+	}
+
+	if m.hasCapacityLimits() == false {
+		return m.writeRows(payloads, rows)
+	}
+
+	m.capacityLock.Lock()
+	defer m.capacityLock.Unlock()
+
+	for i, row := range rows {
+		err := m.reserveCapacity(ctx, int64(len(row)+2))
+		if err != nil {
+			return i, err
+		}
+
+		written, err := m.writeRows(payloads[i:i+1], rows[i:i+1])
+		if err != nil {
+			return i + written, err
+		}
+	}
+
+	return len(rows), nil
+}
+
+//returns the number of records written, they are written also when waiting for the sync fails
+func (m *manager) writeRows(payloads []string, rows []string) (int, error) {
 	m.writeLock.Lock()
 
-	if m.mode == gcMode {
-		err = m.writeDB.write(payload)
-	} else {
-		err = m.mainDB.write(payload)
+	if m.isOpen() == false {
+		m.writeLock.Unlock()
+		return 0, ErrClosed
 	}
+
+	db, written, err := m.appendRows(payloads, rows)
 	m.writeLock.Unlock()
 
-	return err
+	if err != nil {
+		return written, err
+	}
+
+	//waiting for the sync happens without holding the lock, so that concurrent writes can share a group commit
+	return written, db.commit()
+}
+
+//writes the rows also while the database is being closed, used for the records written back by the streams
+func (m *manager) storeRows(payloads []string, rows []string) error {
+	m.writeLock.Lock()
+	db, _, err := m.appendRows(payloads, rows)
+	m.writeLock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return db.commit()
+}
+
+/**
+Callers must hold the writeLock, the rows are the encoded payloads. Returns the database the rows were written to
+and the number of rows written
+*/
+func (m *manager) appendRows(payloads []string, rows []string) (db *database, written int, err error) {
+	db = m.mainDB
+	if m.operationLock == nil && m.mode == gcMode {
+		db = m.writeDB
+	}
+
+	//the rows can be read as soon as they are written, the changeLock keeps the reads from reporting them
+	//consumed before they have been reported written
+	if m.changes != nil {
+		m.changeLock.Lock()
+		defer m.changeLock.Unlock()
+		defer func() {
+			if written > 0 {
+				m.reportChange(Change{Kind: ChangeWrite, Records: payloads[:written]})
+			}
+		}()
+	}
+
+	if m.operationLock != nil {
+		err = m.writeShared(rows...)
+		if err != nil {
+			return db, 0, err
+		}
+
+		m.recordsWritten(len(rows))
+		return db, len(rows), nil
+	}
+
+	for i, row := range rows {
+		err = db.writeRow(row)
+		if err != nil {
+			m.recordsWritten(i)
+			return db, i, err
+		}
+	}
+
+	m.recordsWritten(len(rows))
+	return db, len(rows), nil
+}
+
+func (m *manager) recordsWritten(count int) {
+	if count == 0 {
+		return
+	}
+
+	m.activity.recordsWritten(count)
+	m.written.Notify()
 }
 
 func (m *manager) Read() (string, error) {
+	start := time.Now()
+	record, err := m.readRecord()
+	duration := time.Since(start)
+
+	if err == nil {
+		m.activity.recordRead()
+	}
+	m.readLatency.observe(duration)
+	if err != ErrEmpty {
+		m.events.OnRead(ReadEvent{Bytes: len(record.payload), Duration: duration, Err: err})
+	}
+
+	return record.payload, err
+}
+
+/**
+Reads the next record, when the database is empty it waits until a record is written or until the context is
+done. Returns ctx.Err() when the context is done and ErrClosed when the database is closed while waiting
+*/
+func (m *manager) ReadContext(ctx context.Context) (string, error) {
+	for {
+		//taken before reading, so a record written after the read is not missed
+		written := m.written.Wait()
+
+		payload, err := m.Read()
+		if err != ErrEmpty {
+			return payload, err
+		}
+
+		//the other processes do not notify about their writes in shared mode, the database is polled
+		var poll <-chan time.Time
+		if m.operationLock != nil {
+			poll = time.After(time.Millisecond * 25)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-m.closing:
+			return "", ErrClosed
+		case <-written:
+		case <-poll:
+		}
+	}
+}
+
+/**
+Returns the next record without removing it, the next read returns the same record. Returns ErrEmpty when the
+database is empty
+*/
+func (m *manager) Peek() (string, error) {
 	m.readLock.Lock()
-	result, err := m.mainDB.read(true)
-	m.readLock.Unlock()
+	defer m.readLock.Unlock()
+
+	if m.isOpen() == false {
+		return "", ErrClosed
+	}
+
+	var payload string
+	var err error
+
+	if m.operationLock != nil {
+		err = m.sharedOperation(func() error {
+			payload, err = m.peekNext()
+			return err
+		}, m.mainDB, m.writeDB)
+	} else {
+		//the record held by the read stream routine is the next one, it is restored before peeking
+		m.mainDB.pauseReadStream()
+		payload, err = m.peekNext()
+		m.mainDB.resumeReadStream()
+	}
+
+	if err == io.EOF {
+		return "", ErrEmpty
+	}
 
-	return result, err
+	return payload, err
+}
+
+//callers must hold the readLock
+func (m *manager) peekNext() (string, error) {
+	payload, err := m.mainDB.peek()
+
+	if err == io.EOF && m.writeDB.length() > 0 {
+		payload, err = m.writeDB.peek()
+	}
+
+	return payload, err
+}
+
+//reads the next record, the callers count it as read, the records dropped to make space are not counted
+func (m *manager) readRecord() (storedRecord, error) {
+	m.readLock.Lock()
+	defer m.readLock.Unlock()
+
+	if m.isOpen() == false {
+		return storedRecord{}, ErrClosed
+	}
+
+	var record storedRecord
+	var err error
+
+	if m.operationLock != nil {
+		record, err = m.readShared()
+	} else if m.changes != nil {
+		//the record read ahead for the streams is the oldest one, it is not skipped while the changes are reported
+		m.mainDB.pauseReadStream()
+		record, err = m.readNext()
+		m.mainDB.resumeReadStream()
+	} else {
+		record, err = m.readNext()
+	}
+
+	if err == io.EOF {
+		return storedRecord{}, ErrEmpty
+	}
+
+	if err == nil {
+		m.spaceSignal.Signal()
+		m.reportConsumed(1)
+	}
+
+	record.truncation = atomic.LoadInt64(&m.truncations)
+	return record, err
+}
+
+//callers must hold the readLock
+func (m *manager) readNext() (storedRecord, error) {
+	record, err := m.mainDB.readStored(true)
+
+	//records written during garbage collection are stored in the write-only database until they are moved
+	//back to the main database, they are newer than anything in the main database
+	if err == io.EOF && m.writeDB.length() > 0 {
+		record, err = m.writeDB.readStored(true)
+	}
+
+	return record, err
 }
 
 func (m *manager) Truncate() error {
+	if m.settings.ReadOnly {
+		return ErrReadOnly
+	}
+
 	m.readLock.Lock()
 	m.writeLock.Lock()
 	defer m.readLock.Unlock()
 	defer m.writeLock.Unlock()
+	defer m.spaceSignal.Signal()
 
-	return joinErrors(m.mainDB.truncate(), m.gcDB.truncate(), m.writeDB.truncate())
+	if m.isOpen() == false {
+		return ErrClosed
+	}
+
+	//the records held by the streams are not restored after the database has been truncated
+	atomic.AddInt64(&m.truncations, 1)
+	m.mainDB.pauseReadStream()
+	defer m.mainDB.resumeReadStream()
+	m.mainDB.releaseAll()
+
+	var err error
+	if m.operationLock != nil {
+		err = m.sharedOperation(func() error {
+			return m.truncateFiles()
+		}, m.mainDB, m.gcDB, m.writeDB)
+	} else {
+		err = m.truncateFiles()
+	}
+
+	m.activity.truncated()
+
+	if err == nil && m.changes != nil {
+		m.changeLock.Lock()
+		m.reportChange(Change{Kind: ChangeTruncate})
+		m.changeLock.Unlock()
+	}
+
+	return err
+}
+
+func (m *manager) truncateFiles() error {
+	return collectErrors(
+		fileError(m.mainDB.storageFile, m.mainDB.truncate()),
+		fileError(m.gcDB.storageFile, m.gcDB.truncate()),
+		fileError(m.writeDB.storageFile, m.writeDB.truncate()),
+	)
 }
 
 func (m *manager) Length() int64 {
+	if m.isOpen() == false {
+		return 0
+	}
+
+	if m.operationLock != nil {
+		return m.lengthShared()
+	}
 
-	return m.mainDB.length()
+	return m.mainDB.length() + m.writeDB.length()
 }
 
 func (m *manager) Close() error {
-	if m.mode == initialMode {
-		return errors.New("database is not running, CreateDatabase must have failed")
+	return m.CloseContext(context.Background())
+}
+
+//called once by CloseContext()
+func (m *manager) close() error {
+	//new streams are not opened once the state is closing
+	m.streamLock.Lock()
+	streams := m.streams
+	m.streamLock.Unlock()
+
+	//Deliver() returns the record it is holding to the database before the streams are closed
+	m.deliveries.Wait()
+
+	//first close all of the reading streams before acquiring locks
+	for _, stream := range streams {
+		err := stream.Close()
+		if err != nil {
+			m.log.warn("failed closing a stream", "error", err)
+		}
+	}
+
+	//garbage collection that is in progress needs the locks to finish
+	if m.settings.ReadOnly == false {
+		m.gcScheduler.remove(m.gcJob)
 	}
 
 	//acquire locks
@@ -192,20 +755,37 @@ func (m *manager) Close() error {
 	defer m.readLock.Unlock()
 	defer m.writeLock.Unlock()
 
-	//first close all of the reading streams before acquiring locks
-	for _, stream := range m.streams {
-		err := stream.Close()
-		if err != nil {
-			m.log("Failed closing stream", err)
-		}
+	var err error
+
+	if m.settings.ReadOnly {
+		err = m.closeFiles()
+	} else if m.operationLock != nil {
+		err = m.closeShared()
+	} else {
+		//the lock is released only after all of the data has been written to the files
+		err = collectErrors(m.closeFiles(), fileError(lockFileName(m.settings.DBFile), m.lock.release()))
 	}
 
-	m.gcQuitSignal <- true
+	if m.ownsSchedulers {
+		m.syncScheduler.stop()
+		m.gcScheduler.stop()
+	}
+
+	if m.closeHook != nil {
+		m.closeHook()
+	}
 
-	return joinErrors(m.mainDB.close(), m.writeDB.close(), m.gcDB.close())
+	return err
 }
 
 func (m *manager) ReadStream() Stream {
+	m.streamLock.Lock()
+	defer m.streamLock.Unlock()
+
+	//the stream of a closed database delivers nothing
+	if m.isOpen() == false {
+		return createClosedStream(m)
+	}
 
 	stream := createStream(m)
 	m.streams = append(m.streams, stream) //store the stream in the array
@@ -213,18 +793,27 @@ func (m *manager) ReadStream() Stream {
 	return stream
 }
 
-func joinErrors(v ...error) error {
-	errorState := false
-	errorString := ""
-	for _, err := range v {
-		if err != nil {
-			errorState = true
-			errorString += err.Error() + "; "
-		}
-	}
+/**
+Streams are closed before the database is closed, returns false when the database is not open anymore. The
+stream has to call streamStopped() once it stops receiving records
+*/
+func (m *manager) registerStream(stream io.Closer) bool {
+	m.streamLock.Lock()
+	defer m.streamLock.Unlock()
 
-	if errorState {
-		return errors.New(errorString)
+	if m.isOpen() == false {
+		return false
 	}
-	return nil
+
+	m.streams = append(m.streams, stream)
+	m.streamStarted()
+	return true
+}
+
+func (m *manager) closeFiles() error {
+	return collectErrors(
+		fileError(m.mainDB.storageFile, m.mainDB.close()),
+		fileError(m.writeDB.storageFile, m.writeDB.close()),
+		fileError(m.gcDB.storageFile, m.gcDB.close()),
+	)
 }
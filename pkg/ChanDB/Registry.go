@@ -0,0 +1,245 @@
+package ChanDB
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	ErrQueueNotFound = errors.New("queue does not exist")
+	//returned for the names that cannot be used as a directory name
+	ErrInvalidQueueName = errors.New("invalid queue name")
+)
+
+/**
+Registry of named queues stored in subdirectories of one directory. All of the queues share one sync
+scheduler and one garbage collection scheduler, so the number of goroutines does not grow with the number
+of queues
+*/
+type Registry struct {
+	dir           string
+	options       []Option
+	lock          *sync.Mutex
+	queues        map[string]*manager
+	syncScheduler *scheduler
+	gcScheduler   *scheduler
+	closed        bool
+	//the queues being dropped, the channel is closed once the files have been removed
+	dropping map[string]chan bool
+}
+
+/**
+Options are applied to every queue opened by the registry
+*/
+func CreateRegistry(dir string, options ...Option) (*Registry, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Registry{
+		dir:           dir,
+		options:       options,
+		lock:          &sync.Mutex{},
+		queues:        make(map[string]*manager),
+		dropping:      make(map[string]chan bool),
+		syncScheduler: createScheduler(),
+		gcScheduler:   createScheduler(),
+	}, nil
+}
+
+/**
+Returns the queue with the given name, the queue is created when it does not exist. Queue names may contain
+letters, digits, '-', '_' and '.', and may not start with '.'. A queue that is being dropped is created again
+once its files have been removed
+*/
+func (r *Registry) OpenQueue(name string) (*manager, error) {
+	err := validateQueueName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.waitForDrop(name)
+
+	return r.openQueue(name)
+}
+
+//callers must hold the lock
+func (r *Registry) openQueue(name string) (*manager, error) {
+	if r.closed {
+		return nil, ErrClosed
+	}
+
+	if queue, ok := r.queues[name]; ok {
+		return queue, nil
+	}
+
+	queue, err := openDirectory(filepath.Join(r.dir, name), r.syncScheduler, r.gcScheduler, r.options)
+	if err != nil {
+		return nil, err
+	}
+
+	queue.closeHook = func() {
+		r.forget(name, queue)
+	}
+	r.queues[name] = queue
+
+	return queue, nil
+}
+
+//returns true when the queue has been created, it does not have to be open
+func (r *Registry) HasQueue(name string) (bool, error) {
+	err := validateQueueName(name)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(filepath.Join(r.dir, name, metadataFileName))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+//returns the names of all of the queues stored in the registry directory, including the ones that are not open
+func (r *Registry) ListQueues() ([]string, error) {
+	entries, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() == false || validateQueueName(entry.Name()) != nil {
+			continue
+		}
+
+		_, err = os.Stat(filepath.Join(r.dir, entry.Name(), metadataFileName))
+		if err == nil {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+/**
+Closes the queue and deletes all of its files. Queues that are opened by another process can not be dropped,
+OpenQueue() waits until the files have been deleted
+*/
+func (r *Registry) DropQueue(name string) error {
+	err := validateQueueName(name)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	r.waitForDrop(name)
+
+	exists, err := r.HasQueue(name)
+	if err == nil && exists == false {
+		err = ErrQueueNotFound
+	}
+
+	//opening the queue makes sure that no other process is using it
+	var queue *manager
+	if err == nil {
+		queue, err = r.openQueue(name)
+	}
+
+	if err != nil {
+		r.lock.Unlock()
+		return err
+	}
+
+	//the lock can not be held while closing the queue, the queue removes itself from the registry when closed
+	done := make(chan bool)
+	r.dropping[name] = done
+	r.lock.Unlock()
+
+	err = queue.Close()
+	if err == nil {
+		err = os.RemoveAll(filepath.Join(r.dir, name))
+	}
+
+	r.lock.Lock()
+	delete(r.dropping, name)
+	r.lock.Unlock()
+	close(done)
+
+	return err
+}
+
+//callers must hold the lock, it is released while waiting for the queue to be dropped
+func (r *Registry) waitForDrop(name string) {
+	for {
+		done, ok := r.dropping[name]
+		if ok == false {
+			return
+		}
+
+		r.lock.Unlock()
+		<-done
+		r.lock.Lock()
+	}
+}
+
+//closes all of the open queues and stops the schedulers
+func (r *Registry) Close() error {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return nil
+	}
+	r.closed = true
+
+	queues := make([]*manager, 0, len(r.queues))
+	for _, queue := range r.queues {
+		queues = append(queues, queue)
+	}
+	r.lock.Unlock()
+
+	errs := make([]error, 0)
+	for _, queue := range queues {
+		errs = append(errs, queue.Close())
+	}
+
+	r.syncScheduler.stop()
+	r.gcScheduler.stop()
+
+	return collectErrors(errs...)
+}
+
+func (r *Registry) forget(name string, queue *manager) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.queues[name] == queue {
+		delete(r.queues, name)
+	}
+}
+
+func validateQueueName(name string) error {
+	if len(name) == 0 || name[0] == '.' {
+		return fmt.Errorf("%w '%s'", ErrInvalidQueueName, name)
+	}
+
+	for _, char := range name {
+		valid := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9') ||
+			char == '-' || char == '_' || char == '.'
+
+		if valid == false {
+			return fmt.Errorf("%w '%s'", ErrInvalidQueueName, name)
+		}
+	}
+
+	return nil
+}
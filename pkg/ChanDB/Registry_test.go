@@ -0,0 +1,106 @@
+package ChanDB
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDropQueueWhileOpening(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chandb-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	registry, err := CreateRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+
+	for i := 0; i < 50; i++ {
+		queue, err := registry.OpenQueue("orders")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = queue.Write("before")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dropped := make(chan bool)
+		go func() {
+			defer close(dropped)
+
+			err := registry.DropQueue("orders")
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+
+		//the queue is opened again and again while it is being dropped
+		opened := make(map[*manager]bool)
+		for done := false; done == false; {
+			select {
+			case <-dropped:
+				done = true
+			default:
+			}
+
+			queue, err := registry.OpenQueue("orders")
+			if err != nil {
+				t.Fatal(err)
+			}
+			opened[queue] = true
+		}
+
+		//the files of the queues that are still open must not have been removed
+		for queue := range opened {
+			if queue.isOpen() == false {
+				continue
+			}
+
+			stored, err := os.Stat(queue.settings.DBFile)
+			if err != nil {
+				t.Fatalf("iteration %d: %v", i, err)
+			}
+
+			handle, err := queue.mainDB.fileHandle.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if os.SameFile(stored, handle) == false {
+				t.Fatalf("iteration %d: the files of an open queue have been removed", i)
+			}
+
+			queue.Close()
+		}
+	}
+}
+
+func TestDropQueueNotFound(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chandb-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	registry, err := CreateRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+
+	err = registry.DropQueue("missing")
+	if err != ErrQueueNotFound {
+		t.Fatalf("expected ErrQueueNotFound, got %v", err)
+	}
+
+	_, err = registry.OpenQueue("missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
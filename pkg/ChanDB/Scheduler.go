@@ -0,0 +1,110 @@
+package ChanDB
+
+import (
+	"sync"
+	"time"
+)
+
+const schedulerResolution = time.Millisecond * 25
+
+/**
+Runs periodic jobs one after another on a single goroutine. Sync calls and garbage collection are scheduled
+this way, so databases sharing a scheduler do not need a goroutine of their own for each of them
+*/
+type scheduler struct {
+	lock       *sync.Mutex
+	runLock    *sync.Mutex
+	jobs       map[*job]bool
+	quitSignal chan bool
+	done       chan bool
+}
+
+type job struct {
+	interval time.Duration
+	lastRun  time.Time
+	run      func()
+}
+
+func createScheduler() *scheduler {
+	instance := &scheduler{
+		lock:       &sync.Mutex{},
+		runLock:    &sync.Mutex{},
+		jobs:       make(map[*job]bool),
+		quitSignal: make(chan bool, 1),
+		done:       make(chan bool, 1),
+	}
+
+	go instance.schedulerRoutine()
+
+	return instance
+}
+
+//the job runs for the first time after the interval has passed
+func (s *scheduler) add(interval time.Duration, run func()) *job {
+	instance := &job{
+		interval: interval,
+		lastRun:  time.Now(),
+		run:      run,
+	}
+
+	s.lock.Lock()
+	s.jobs[instance] = true
+	s.lock.Unlock()
+
+	return instance
+}
+
+/**
+Removes the job, waits for the job to finish when it is running. Must not be called from a job running on
+the same scheduler
+*/
+func (s *scheduler) remove(j *job) {
+	s.runLock.Lock()
+	defer s.runLock.Unlock()
+
+	s.lock.Lock()
+	delete(s.jobs, j)
+	s.lock.Unlock()
+}
+
+//stops the scheduler after the running jobs have finished
+func (s *scheduler) stop() {
+	s.quitSignal <- true
+	<-s.done
+}
+
+func (s *scheduler) schedulerRoutine() {
+	ticker := time.NewTicker(schedulerResolution)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.quitSignal:
+			s.done <- true
+			return
+		case now := <-ticker.C:
+			s.runJobs(now)
+		}
+	}
+}
+
+func (s *scheduler) runJobs(now time.Time) {
+	s.runLock.Lock()
+	defer s.runLock.Unlock()
+
+	due := make([]*job, 0)
+
+	s.lock.Lock()
+	for j := range s.jobs {
+		if now.Sub(j.lastRun) >= j.interval {
+			due = append(due, j)
+		}
+	}
+	s.lock.Unlock()
+
+	for _, j := range due {
+		j.run()
+		//the interval is counted from the end of the previous run, like the routines sleeping between runs
+		j.lastRun = time.Now()
+	}
+}
@@ -0,0 +1,202 @@
+package ChanDB
+
+import "os"
+
+/**
+Shared mode lets several processes use the same database files. The number of records and the read position
+are stored in the header of each file, every operation holds the operation lock and loads the state from the
+header before touching the file and stores it back to the header afterwards
+*/
+
+//callers must hold the operation lock
+func (d *database) loadSharedState() error {
+	replaced, err := d.fileReplaced()
+	if err != nil {
+		return err
+	}
+
+	//garbage collection in another process has replaced the file
+	if replaced {
+		return d.reload()
+	}
+
+	err = d.header.Read(d.fileHandle)
+	if err != nil {
+		return err
+	}
+
+	err = d.setDatabaseSize()
+	if err != nil {
+		return err
+	}
+
+	head := d.header.Head
+	if head < HeaderBytes {
+		head = HeaderBytes
+	}
+
+	d.setRecordsStored(d.header.Records)
+	d.setBytesStored(d.header.Bytes)
+	d.scannerEOF = false
+	d.seekScanner(head)
+
+	return nil
+}
+
+//callers must hold the operation lock
+func (d *database) storeSharedState() error {
+	d.updateHeader()
+
+	return d.header.write(d.fileHandle)
+}
+
+func (d *database) fileReplaced() (bool, error) {
+	current, err := d.fileHandle.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	stored, err := os.Stat(d.storageFile)
+	if err != nil {
+		return false, err
+	}
+
+	return os.SameFile(current, stored) == false, nil
+}
+
+//the file handle points to a file that is no longer in use, it is closed without updating the header
+func (d *database) reload() error {
+	d.unscheduleSync()
+
+	err := d.closeFileHandle()
+	if err != nil {
+		return err
+	}
+
+	return d.loadDatabase()
+}
+
+/**
+Runs the operation while holding the operation lock, the state of the given databases is loaded from the files
+before the operation and stored back to the files after it
+*/
+func (m *manager) sharedOperation(operation func() error, databases ...*database) error {
+	err := m.operationLock.acquire()
+	if err != nil {
+		return err
+	}
+	defer m.operationLock.release()
+
+	for _, db := range databases {
+		err = db.loadSharedState()
+		if err != nil {
+			return err
+		}
+	}
+
+	operationErr := operation()
+
+	for _, db := range databases {
+		err = db.storeSharedState()
+		if err != nil {
+			return err
+		}
+	}
+
+	return operationErr
+}
+
+func (m *manager) writeShared(rows ...string) error {
+	return m.sharedOperation(func() error {
+		for _, row := range rows {
+			err := m.mainDB.writeRow(row)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, m.mainDB)
+}
+
+func (m *manager) readShared() (result storedRecord, err error) {
+	err = m.sharedOperation(func() error {
+		result, err = m.readNext()
+		return err
+	}, m.mainDB, m.writeDB)
+
+	return result, err
+}
+
+func (m *manager) lengthShared() int64 {
+	err := m.sharedOperation(func() error {
+		return nil
+	}, m.mainDB, m.writeDB)
+
+	if err != nil {
+		m.log.warn("failed to load the number of records in shared mode", "error", err)
+	}
+
+	return m.mainDB.length() + m.writeDB.length()
+}
+
+/**
+Garbage collection in shared mode holds the operation lock from start to finish, so the other processes are
+not using the files while they are being replaced. Writes are not redirected to the write-only database
+*/
+func (m *manager) garbageCollectShared() error {
+	m.readLock.Lock()
+	m.writeLock.Lock()
+	defer m.readLock.Unlock()
+	defer m.writeLock.Unlock()
+
+	if m.isOpen() == false {
+		return ErrClosed
+	}
+
+	//writes are not redirected in shared mode, the mode only tells Stats() that garbage collection is running
+	m.setMode(gcMode)
+	defer m.setMode(normalMode)
+
+	err := m.sharedOperation(func() error {
+		err := m.gcDB.truncate()
+		if err != nil {
+			return err
+		}
+
+		err = m.moveRecordsToGCDB()
+		if err != nil {
+			return err
+		}
+
+		return m.moveGCDataToMainDB()
+	}, m.mainDB, m.gcDB)
+
+	if err != nil {
+		m.log.error("garbage collection failed in shared mode", "error", err)
+	}
+
+	return err
+}
+
+func (m *manager) closeShared() error {
+	err := m.operationLock.acquire()
+	if err != nil {
+		return err
+	}
+
+	databases := []*database{m.mainDB, m.writeDB, m.gcDB}
+	errs := make([]error, 0)
+
+	for _, db := range databases {
+		errs = append(errs, fileError(db.storageFile, db.loadSharedState()), fileError(db.storageFile, db.close()))
+	}
+
+	operationLockFile := operationLockFileName(m.settings.DBFile)
+	errs = append(errs,
+		fileError(operationLockFile, m.operationLock.release()),
+		fileError(operationLockFile, m.operationLock.close()),
+		fileError(lockFileName(m.settings.DBFile), m.lock.release()),
+	)
+
+	return collectErrors(errs...)
+}
@@ -0,0 +1,324 @@
+package ChanDB
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/theorx/ChanDB/internal/Version"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+/**
+A snapshot starts with a line holding the snapshotHeader as JSON, it is followed by the records as they are stored
+in the database files, one " row\n" line per record in the order they are read
+*/
+const snapshotFormat int = 1
+
+//the context is checked after this many records
+const snapshotCheckInterval = 1024
+
+type snapshotHeader struct {
+	Format   int    `json:"snapshotFormat"`
+	Version  string `json:"version"`
+	Encoding string `json:"encoding,omitempty"`
+	Records  int64  `json:"records"`
+}
+
+/**
+Visits the records of the database at the time of the call, the records held by the streams are passed to
+visitHeld as payloads, the records stored in the files are passed to visitRow as encoded rows. Returns the
+sequence of the last change the records include.
+
+The readLock is held until the records have been visited, the writeLock only while the size of the files is taken,
+the records written after it are stored after that size and they are not visited
+*/
+func (m *manager) snapshot(visitHeld func(payload string) error, visitRow func(row string) error) (uint64, error) {
+	if m.settings.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	if m.operationLock != nil {
+		return 0, fmt.Errorf("%w: the records of a shared database can not be visited", ErrInvalidSettings)
+	}
+
+	m.readLock.Lock()
+	defer m.readLock.Unlock()
+
+	if m.isOpen() == false {
+		return 0, ErrClosed
+	}
+
+	//the record held by the read stream routine is restored to the file, the records held by the streams are
+	//older than the records in the files
+	m.mainDB.pauseReadStream()
+	defer m.mainDB.resumeReadStream()
+
+	m.writeLock.Lock()
+	m.changeLock.Lock()
+	held := m.mainDB.heldRecords()
+	mainSize := m.mainDB.fileSize()
+	writeSize := m.writeDB.fileSize()
+	sequence := m.changeSequence
+	m.changeLock.Unlock()
+	m.writeLock.Unlock()
+
+	for _, record := range held {
+		err := visitHeld(record.payload)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	//the records written while the garbage is collected are read after the records of the main file
+	err := m.mainDB.forEachRow(mainSize, visitRow)
+	if err != nil {
+		return 0, fileError(m.mainDB.storageFile, err)
+	}
+
+	err = m.writeDB.forEachRow(writeSize, visitRow)
+	if err != nil {
+		return 0, fileError(m.writeDB.storageFile, err)
+	}
+
+	return sequence, nil
+}
+
+/**
+Visits the records of the database as rows encoded the way they are stored, returns the number of records
+*/
+func (m *manager) snapshotRows(ctx context.Context, write func(row string) error) (int64, error) {
+	records := int64(0)
+
+	visitRow := func(row string) error {
+		if records%snapshotCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		records++
+
+		return write(row)
+	}
+
+	_, err := m.snapshot(func(payload string) error {
+		row, err := m.mainDB.encoding.encode(payload)
+		if err != nil {
+			return err
+		}
+
+		return visitRow(row)
+	}, visitRow)
+
+	return records, err
+}
+
+/**
+Writes a consistent image of the records of the database to the writer, the snapshot can be turned into a database
+with Restore(). The records are copied to a temporary file next to the database file first, writing continues
+while they are copied and reading waits until they have been copied, the writer is written to after that. The
+records stay encoded, a database restored from the snapshot has to use the same compression and encryption
+*/
+func (m *manager) Snapshot(ctx context.Context, writer io.Writer) error {
+	file, err := ioutil.TempFile(filepath.Dir(m.settings.DBFile), "chandb-snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	buffer := bufio.NewWriter(file)
+	records, err := m.snapshotRows(ctx, func(row string) error {
+		_, err := buffer.WriteString(" " + row + "\n")
+		return err
+	})
+	if err == nil {
+		err = buffer.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	header, err := json.Marshal(snapshotHeader{
+		Format:   snapshotFormat,
+		Version:  Version.Version,
+		Encoding: m.mainDB.encoding.name(),
+		Records:  records,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(append(header, '\n'))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(writer, &contextReader{ctx: ctx, reader: file})
+
+	return err
+}
+
+/**
+Creates a database directory holding a consistent image of the records of the database, the directory can be
+opened with Open() using the same compression and encryption. The directory must not exist or it must be empty.
+Writing continues while the records are copied, reading waits until they have been copied
+*/
+func (m *manager) Backup(dir string) error {
+	return createDatabaseDirectory(dir, m.mainDB.encoding.name(), func(write func(row string) error) error {
+		_, err := m.snapshotRows(context.Background(), write)
+		return err
+	})
+}
+
+/**
+Creates a database in the directory from a snapshot written by Snapshot() and opens it with the options. The
+directory must not exist or it must be empty. Fails with ErrEncodingMismatch when the options do not give the
+encoding of the snapshot and with ErrInvalidSnapshot when the snapshot is not complete, nothing is left in the
+directory then
+*/
+func Restore(reader io.Reader, dir string, options ...Option) (*manager, error) {
+	settings := &Settings{}
+	for _, option := range options {
+		option(settings)
+	}
+
+	buffer := bufio.NewReader(reader)
+
+	line, err := buffer.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read the header: %v", ErrInvalidSnapshot, err)
+	}
+
+	header := snapshotHeader{}
+	err = json.Unmarshal([]byte(line), &header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse the header: %v", ErrInvalidSnapshot, err)
+	}
+
+	if header.Format != snapshotFormat {
+		return nil, fmt.Errorf("%w: the snapshot has format %d, supported format is %d", ErrIncompatibleFormat, header.Format, snapshotFormat)
+	}
+
+	encoding := createRecordEncoding(settings).name()
+	if header.Records > 0 && header.Encoding != encoding {
+		return nil, fmt.Errorf("%w: the snapshot is stored with encoding %q, the settings use %q", ErrEncodingMismatch, header.Encoding, encoding)
+	}
+
+	err = createDatabaseDirectory(dir, encoding, func(write func(row string) error) error {
+		records := int64(0)
+
+		for {
+			line, err := buffer.ReadString('\n')
+			if err == io.EOF && len(line) == 0 {
+				break
+			}
+			if err == io.EOF {
+				return fmt.Errorf("%w: the last record is not complete", ErrInvalidSnapshot)
+			}
+			if err != nil {
+				return err
+			}
+
+			if line[:1] != " " {
+				return fmt.Errorf("%w: record %d is not stored as a record", ErrInvalidSnapshot, records+1)
+			}
+
+			err = write(line[1 : len(line)-1])
+			if err != nil {
+				return err
+			}
+			records++
+		}
+
+		if records != header.Records {
+			return fmt.Errorf("%w: the snapshot holds %d records, the header tells %d", ErrInvalidSnapshot, records, header.Records)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return Open(dir, options...)
+}
+
+/**
+Creates a database directory and writes the rows given by fill to its database file, the files that have been
+created are removed when it fails
+*/
+func createDatabaseDirectory(dir string, encoding string, fill func(write func(row string) error) error) error {
+	meta, err := createMetadata(dir)
+	if err != nil {
+		return err
+	}
+
+	dbFile := filepath.Join(dir, meta.DBFile)
+	err = writeDatabaseFile(dbFile, encoding, fill)
+	if err != nil {
+		os.Remove(dbFile)
+		os.Remove(filepath.Join(dir, metadataFileName))
+	}
+
+	return err
+}
+
+func writeDatabaseFile(path string, encoding string, fill func(write func(row string) error) error) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	//the records are stored after the header, the header is written once the number of records is known
+	_, err = file.Seek(HeaderBytes, io.SeekStart)
+	if err != nil {
+		return fileError(path, err)
+	}
+
+	buffer := bufio.NewWriter(file)
+	records := int64(0)
+
+	err = fill(func(row string) error {
+		records++
+		_, err := buffer.WriteString(" " + row + "\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = buffer.Flush()
+	if err != nil {
+		return fileError(path, err)
+	}
+
+	header := &Header{
+		Records:  records,
+		Version:  Version.Version,
+		Encoding: encoding,
+	}
+
+	return fileError(path, header.Write(file))
+}
+
+//stops the copying of a snapshot when the context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, r.ctx.Err()
+	}
+
+	return r.reader.Read(p)
+}
@@ -0,0 +1,148 @@
+package ChanDB
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	encodings := map[string][]Option{
+		"plain": nil,
+		"gzip":  {WithCompression(GzipCompressor{})},
+	}
+
+	for name, options := range encodings {
+		base, err := ioutil.TempDir("", "chandb-snapshot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(base)
+
+		db, err := Open(filepath.Join(base, "source"), options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.WriteBatch(numberedRecords("r", 0, 100))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 10; i++ {
+			_, err = db.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		//the records held by a stream are part of the snapshot, also after garbage collection has moved them
+		stream := db.ReadStream()
+		waitForStreams()
+
+		err = db.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		snapshot := &bytes.Buffer{}
+		err = db.Snapshot(context.Background(), snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.Backup(filepath.Join(base, "backup"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//the records written after the snapshot are not in it
+		err = db.Write("after")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stream.Close()
+		db.Close()
+
+		restored, err := Restore(snapshot, filepath.Join(base, "restored"), options...)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		records := readAll(t, restored)
+		restored.Close()
+
+		if reflect.DeepEqual(records, numberedRecords("r", 10, 100)) == false {
+			t.Fatalf("%s: the restored records are %v", name, records)
+		}
+
+		backup, err := Open(filepath.Join(base, "backup"), options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		records = readAll(t, backup)
+		backup.Close()
+
+		if reflect.DeepEqual(records, numberedRecords("r", 10, 100)) == false {
+			t.Fatalf("%s: the records of the backup are %v", name, records)
+		}
+	}
+}
+
+func TestRestoreInvalidSnapshot(t *testing.T) {
+	base, err := ioutil.TempDir("", "chandb-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	db, err := Open(filepath.Join(base, "source"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.WriteBatch(numberedRecords("r", 0, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := &bytes.Buffer{}
+	err = db.Snapshot(context.Background(), snapshot)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Restore(bytes.NewReader(snapshot.Bytes()), filepath.Join(base, "gzip"), WithCompression(GzipCompressor{}))
+	if errors.Is(err, ErrEncodingMismatch) == false {
+		t.Fatalf("restoring with another encoding returned %v", err)
+	}
+
+	truncated := snapshot.Bytes()[:snapshot.Len()-3]
+	_, err = Restore(bytes.NewReader(truncated), filepath.Join(base, "truncated"))
+	if errors.Is(err, ErrInvalidSnapshot) == false {
+		t.Fatalf("restoring a truncated snapshot returned %v", err)
+	}
+
+	//nothing is left behind by a failed restore
+	files, err := ioutil.ReadDir(filepath.Join(base, "truncated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("%d files are left in the directory", len(files))
+	}
+
+	_, err = Restore(strings.NewReader("not a snapshot\n"), filepath.Join(base, "invalid"))
+	if errors.Is(err, ErrInvalidSnapshot) == false {
+		t.Fatalf("restoring an invalid snapshot returned %v", err)
+	}
+}
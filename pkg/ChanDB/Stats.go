@@ -0,0 +1,261 @@
+package ChanDB
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//the rates are averaged over this many seconds
+const rateWindowSeconds = 10
+
+//maximum number of write times kept for estimating the age of the oldest record
+const maxAgeCheckpoints = 4096
+
+/**
+Snapshot of the state of the database. Apart from Length() the values are read without taking the database locks,
+so Stats() is cheap enough to be called from a health check. In shared mode the rates and the age of the oldest
+record only account for the operations of this process
+*/
+type Stats struct {
+	//active records, the same as Length()
+	Records int64
+	//bytes the active records take up in the files
+	Bytes int64
+	//sizes of the database files, including the headers
+	DBFileBytes        int64
+	WriteOnlyFileBytes int64
+	GCFileBytes        int64
+	//bytes of the records that have been read and are waiting for garbage collection to remove them
+	DeadBytes int64
+	//records written and read since the database was opened, the records dropped by OverflowDropOldest are not reads
+	Writes int64
+	Reads  int64
+	//averaged over the last 10 seconds
+	WritesPerSecond float64
+	ReadsPerSecond  float64
+	/**
+	Time since the oldest active record was written, accurate to about a second. The records that were stored
+	before the database was opened are considered to be written when it was opened. Always 0 in shared mode,
+	where the other processes write records as well
+	*/
+	OldestRecordAge time.Duration
+	//open streams, TypedStreams and Deliver() calls
+	Streams int64
+	//last time the records were synced to the disk, zero when no sync has been made yet
+	LastSync time.Time
+	//garbage collection is in progress
+	GarbageCollecting bool
+}
+
+func (m *manager) Stats() Stats {
+	now := time.Now()
+	records := m.Length()
+
+	stats := Stats{
+		Records:            records,
+		Bytes:              m.mainDB.storedBytes() + m.writeDB.storedBytes(),
+		DBFileBytes:        m.mainDB.fileSize(),
+		WriteOnlyFileBytes: m.writeDB.fileSize(),
+		GCFileBytes:        m.gcDB.fileSize(),
+		DeadBytes:          m.mainDB.deadBytes() + m.writeDB.deadBytes(),
+		Streams:            atomic.LoadInt64(&m.activeStreams),
+		GarbageCollecting:  m.getMode() == gcMode,
+	}
+
+	stats.Writes, stats.WritesPerSecond = m.activity.writes.snapshot(now)
+	stats.Reads, stats.ReadsPerSecond = m.activity.reads.snapshot(now)
+
+	if m.operationLock == nil && records > 0 {
+		stats.OldestRecordAge = m.activity.oldestRecordAge(now)
+	}
+
+	stats.LastSync = m.mainDB.lastSyncTime()
+	if writeSync := m.writeDB.lastSyncTime(); writeSync.After(stats.LastSync) {
+		stats.LastSync = writeSync
+	}
+
+	return stats
+}
+
+/**
+Counts the operations for Stats(). The age of the oldest record is estimated from the times the records were
+written: every record gets a sequence number and the time of a write is kept at most once per second, so the
+memory used does not grow with the number of records
+*/
+type activity struct {
+	lock        *sync.Mutex
+	writes      *rateMeter
+	reads       *rateMeter
+	written     int64
+	oldest      int64
+	checkpoints []ageCheckpoint
+}
+
+//records starting from the sequence number have been written at the given time or after it
+type ageCheckpoint struct {
+	sequence int64
+	time     time.Time
+}
+
+func createActivity(records int64) *activity {
+	now := time.Now()
+
+	instance := &activity{
+		lock:    &sync.Mutex{},
+		writes:  createRateMeter(now),
+		reads:   createRateMeter(now),
+		written: records,
+	}
+
+	if records > 0 {
+		instance.checkpoints = []ageCheckpoint{{sequence: 0, time: now}}
+	}
+
+	return instance
+}
+
+func (a *activity) recordsWritten(count int) {
+	now := time.Now()
+	a.writes.add(int64(count), now)
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	last := len(a.checkpoints) - 1
+	if last < 0 || now.Sub(a.checkpoints[last].time) >= time.Second {
+		if len(a.checkpoints) >= maxAgeCheckpoints {
+			a.thinCheckpoints()
+		}
+		a.checkpoints = append(a.checkpoints, ageCheckpoint{sequence: a.written, time: now})
+	}
+
+	a.written += int64(count)
+}
+
+func (a *activity) recordRead() {
+	a.reads.add(1, time.Now())
+	a.recordRemoved()
+}
+
+//the oldest record has left the database without being read, such as a record dropped for the capacity limits
+func (a *activity) recordRemoved() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.oldest++
+
+	//the checkpoints of the records that have all been read are not needed anymore
+	for len(a.checkpoints) > 1 && a.checkpoints[1].sequence <= a.oldest {
+		a.checkpoints = a.checkpoints[1:]
+	}
+}
+
+//the record has been put back to the beginning of the database, it does not count as read anymore
+func (a *activity) recordRestored() {
+	a.reads.add(-1, time.Now())
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.oldest--
+
+	if len(a.checkpoints) > 0 && a.checkpoints[0].sequence > a.oldest {
+		a.checkpoints[0].sequence = a.oldest
+	}
+}
+
+func (a *activity) truncated() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.oldest = a.written
+	a.checkpoints = nil
+}
+
+func (a *activity) oldestRecordAge(now time.Time) time.Duration {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.oldest >= a.written || len(a.checkpoints) == 0 {
+		return 0
+	}
+
+	return now.Sub(a.checkpoints[0].time)
+}
+
+//drops every other checkpoint, the first one is kept so the age of the oldest record is never underestimated
+func (a *activity) thinCheckpoints() {
+	kept := a.checkpoints[:0]
+	for i := 0; i < len(a.checkpoints); i += 2 {
+		kept = append(kept, a.checkpoints[i])
+	}
+
+	a.checkpoints = kept
+}
+
+/**
+Counts events in one second buckets over the last rateWindowSeconds seconds
+*/
+type rateMeter struct {
+	lock    *sync.Mutex
+	started time.Time
+	total   int64
+	counts  [rateWindowSeconds]int64
+	seconds [rateWindowSeconds]int64
+}
+
+func createRateMeter(now time.Time) *rateMeter {
+	return &rateMeter{
+		lock:    &sync.Mutex{},
+		started: now,
+	}
+}
+
+func (r *rateMeter) add(count int64, now time.Time) {
+	second := now.Unix()
+	bucket := second % rateWindowSeconds
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.seconds[bucket] != second {
+		r.seconds[bucket] = second
+		r.counts[bucket] = 0
+	}
+
+	r.counts[bucket] += count
+	r.total += count
+}
+
+//returns the total number of events and the events per second
+func (r *rateMeter) snapshot(now time.Time) (int64, float64) {
+	second := now.Unix()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	count := int64(0)
+	for i := range r.counts {
+		if second-r.seconds[i] < rateWindowSeconds {
+			count += r.counts[i]
+		}
+	}
+
+	//the buckets cover the seconds before the current one and the part of the current second that has passed,
+	//the rate of a database opened a moment ago is averaged over the time it has been open
+	window := float64(rateWindowSeconds-1) + float64(now.Nanosecond())/float64(time.Second)
+	if open := now.Sub(r.started).Seconds(); open < window {
+		window = open
+	}
+	if window < 1 {
+		window = 1
+	}
+
+	//a record read in the previous second and restored in this one leaves the bucket negative
+	if count < 0 {
+		count = 0
+	}
+
+	return r.total, float64(count) / window
+}
@@ -0,0 +1,58 @@
+package ChanDB
+
+import (
+	"testing"
+)
+
+func TestStatsCounters(t *testing.T) {
+	db, cleanup := openTestDatabase(t, WithDurability(SyncAlways, 0))
+	defer cleanup()
+
+	if stats := db.Stats(); stats != (Stats{DBFileBytes: stats.DBFileBytes, WriteOnlyFileBytes: stats.WriteOnlyFileBytes, GCFileBytes: stats.GCFileBytes}) {
+		t.Fatalf("stats of an empty database are %+v", stats)
+	}
+
+	err := db.WriteBatch([]string{"aaaa", "bbbb", "cccc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Write("dddd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := db.Stats()
+	//every record is stored as " record\n"
+	if stats.Records != 4 || stats.Bytes != 4*6 || stats.Writes != 4 || stats.WritesPerSecond <= 0 || stats.LastSync.IsZero() {
+		t.Fatalf("stats after the writes are %+v", stats)
+	}
+
+	_, err = db.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := db.ReadStream()
+	<-stream.Stream()
+
+	if streams := db.Stats().Streams; streams != 1 {
+		t.Fatalf("%d streams are counted while one is open", streams)
+	}
+
+	//the record the stream read ahead is restored, it is not counted as read
+	stream.Close()
+	stats = db.Stats()
+	if stats.Reads != 2 || stats.ReadsPerSecond <= 0 || stats.Streams != 0 || stats.DeadBytes == 0 {
+		t.Fatalf("stats after the reads are %+v", stats)
+	}
+
+	err = db.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats = db.Stats()
+	if stats.Records != 2 || stats.Bytes != 2*6 || stats.DeadBytes != 0 || stats.GarbageCollecting {
+		t.Fatalf("stats after garbage collection are %+v", stats)
+	}
+}
@@ -1,7 +1,10 @@
 package ChanDB
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -11,45 +14,218 @@ type Stream interface {
 }
 
 type stream struct {
-	out        chan string
-	dbManager  *manager
-	killSignal chan bool
-	done       chan bool
-	isOpen     bool
-	closeLock  *sync.Mutex
+	out       chan string
+	dbManager *manager
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan bool
+	isOpen    bool
+	closeLock *sync.Mutex
 }
 
 func createStream(manager *manager) *stream {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	instance := &stream{
-		dbManager:  manager,
-		out:        make(chan string),
-		killSignal: make(chan bool, 1),
-		done:       make(chan bool, 1),
-		closeLock:  &sync.Mutex{},
-		isOpen:     true,
+		dbManager: manager,
+		out:       make(chan string),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan bool),
+		closeLock: &sync.Mutex{},
+		isOpen:    true,
 	}
 
+	manager.streamStarted()
 	go instance.streamRoutine()
 
 	return instance
 }
 
+func createClosedStream(manager *manager) *stream {
+	instance := &stream{
+		dbManager: manager,
+		out:       make(chan string),
+		closeLock: &sync.Mutex{},
+		isOpen:    false,
+	}
+
+	close(instance.out)
+
+	return instance
+}
+
+/**
+A record that has been read but not received when the stream is closed is restored to its place in the database
+*/
 func (s *stream) streamRoutine() {
-	readChan := s.dbManager.mainDB.streamReads()
+	defer close(s.done)
+	defer s.dbManager.streamStopped()
+
 	for {
+		record, ok := s.dbManager.nextStreamRecord(s.ctx)
+		if ok == false {
+			return
+		}
+
 		select {
-		case <-s.killSignal:
-			s.done <- true
+		case s.out <- record.payload:
+			s.dbManager.streamDelivered(record)
+		case <-s.ctx.Done():
+			s.dbManager.restoreRecord(record)
 			return
-		case data, ok := <-readChan:
-			if ok == false {
-				return
-			}
-			s.out <- data
 		}
 	}
 }
 
+/**
+Called when a stream, a TypedStream or Deliver() starts receiving records, callers must hold the streamLock.
+Starts reading the records for the streams, in shared mode the streams poll the database instead
+*/
+func (m *manager) streamStarted() {
+	m.events.OnStreamOpen(StreamEvent{Streams: atomic.AddInt64(&m.activeStreams, 1)})
+
+	if m.operationLock == nil {
+		m.mainDB.streamReads()
+	}
+}
+
+/**
+Called when a stream has stopped receiving records. Once the last stream has stopped, the records are not read
+ahead anymore and the record that has been read for the streams is restored, so Read() and Length() see it
+*/
+func (m *manager) streamStopped() {
+	m.streamLock.Lock()
+	defer m.streamLock.Unlock()
+
+	streams := atomic.AddInt64(&m.activeStreams, -1)
+	if streams == 0 && m.operationLock == nil {
+		m.mainDB.stopStreamReads()
+		//the record the streams were holding is available again
+		m.written.Notify()
+	}
+
+	m.events.OnStreamClose(StreamEvent{Streams: streams})
+}
+
+//called when a stream has handed the record to its receiver
+func (m *manager) streamDelivered(record storedRecord) {
+	m.changeLock.Lock()
+	record.db.release(record)
+	//the record has been removed already when the database was truncated after it was read
+	if record.truncation == atomic.LoadInt64(&m.truncations) {
+		m.reportChange(Change{Kind: ChangeConsume, Count: 1})
+	}
+	m.changeLock.Unlock()
+
+	m.events.OnRead(ReadEvent{Bytes: len(record.payload), Stream: true})
+}
+
+/**
+Waits for the next record for a stream until the context is done. In shared mode the other processes do not
+signal about new records, the records are polled from the database
+*/
+func (m *manager) nextStreamRecord(ctx context.Context) (storedRecord, bool) {
+	if m.operationLock == nil {
+		select {
+		case <-ctx.Done():
+			return storedRecord{}, false
+		case record := <-m.mainDB.readStream:
+			record.truncation = atomic.LoadInt64(&m.truncations)
+			m.activity.recordRead()
+			return record, true
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return storedRecord{}, false
+		default:
+		}
+
+		record, err := m.readRecord()
+		if err == nil {
+			m.activity.recordRead()
+			return record, true
+		}
+
+		if errors.Is(err, ErrEmpty) == false {
+			m.log.error("stream failed reading from a shared database", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return storedRecord{}, false
+		case <-time.After(time.Millisecond * 25):
+		}
+	}
+}
+
+/**
+Returns a record that has been read but not delivered to its place in the database, so the order of the records
+stays the same. Garbage collection moves the held records to the new file, the record is written to the end of the
+database only when its place is not known anymore, or while the changes are listened to
+*/
+func (m *manager) restoreRecord(record storedRecord) {
+	if m.settings.ReadOnly {
+		return
+	}
+
+	//garbage collection and the reads hold the readLock while they are moving the records
+	m.readLock.Lock()
+
+	//the record has been removed by truncating the database
+	if record.truncation != atomic.LoadInt64(&m.truncations) {
+		m.readLock.Unlock()
+		return
+	}
+
+	var err error
+	if m.changes != nil {
+		//the listeners of the changes can not put the record back to its place, it is moved to the end instead
+		err = errRecordMoved
+	} else if m.operationLock != nil {
+		err = m.sharedOperation(func() error {
+			return record.db.restore(record)
+		}, record.db)
+	} else {
+		//the record held by the read stream routine is newer, it is restored first so the order stays the same
+		record.db.pauseReadStream()
+		err = record.db.restore(record)
+		record.db.resumeReadStream()
+	}
+	if err == nil {
+		m.activity.recordRestored()
+		m.written.Notify()
+	}
+
+	//a record that is not restored in place is reported consumed before the readLock is released, it is
+	//reported written again once it has been written to the end
+	record.db.release(record)
+	if err != nil {
+		m.reportConsumed(1)
+	}
+	m.readLock.Unlock()
+
+	if err == nil {
+		return
+	}
+
+	if err != errRecordMoved {
+		m.log.warn("failed restoring a record in place, writing it to the end of the database", "file", record.db.storageFile, "error", err)
+	}
+
+	row, err := record.db.encoding.encode(record.payload)
+	if err == nil {
+		err = m.storeRows([]string{record.payload}, []string{row})
+	}
+
+	if err != nil {
+		m.log.error("failed writing back a record, the record is lost", "payload", record.payload, "error", err)
+	}
+}
+
 func (s *stream) Close() error {
 	s.closeLock.Lock()
 	defer s.closeLock.Unlock()
@@ -59,28 +235,14 @@ func (s *stream) Close() error {
 	}
 
 	s.isOpen = false
-	//send close signal to the reading routine
-	s.killSignal <- true
+	//stop the reading routine and wait until it has restored the record it was holding
+	s.cancel()
+	<-s.done
 
-	//handle reading from the out channel if it is not empty
-	for {
-		select {
-		case data, _ := <-s.out:
-			if len(data) > 0 {
-				err := s.dbManager.mainDB.write(data)
-				if err != nil {
-					s.dbManager.log("Failed writing back from the stream", data, err)
-				}
-			}
-		case <-s.done:
-			s.dbManager.log("A reading stream has been closed!")
-
-			close(s.out) //close the channel after the stream has finished
-			return nil
-		default:
-			time.Sleep(time.Microsecond)
-		}
-	}
+	s.dbManager.log.debug("stream closed")
+	close(s.out) //close the channel after the stream has finished
+
+	return nil
 }
 
 func (s *stream) Stream() <-chan string {
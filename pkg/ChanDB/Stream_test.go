@@ -0,0 +1,152 @@
+package ChanDB
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, db *manager) []string {
+	records := make([]string, 0)
+
+	for {
+		record, err := db.Read()
+		if errors.Is(err, ErrEmpty) {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		records = append(records, record)
+	}
+}
+
+func numberedRecords(prefix string, from int, to int) []string {
+	records := make([]string, 0)
+	for i := from; i < to; i++ {
+		records = append(records, fmt.Sprintf("%s%d", prefix, i))
+	}
+
+	return records
+}
+
+//the streams are given time to read ahead the records they hold
+func waitForStreams() {
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestStreamRecordsRestoredInPlaceAfterGC(t *testing.T) {
+	for _, compactions := range []int{0, 1, 3} {
+		db, cleanup := openTestDatabase(t)
+		defer cleanup()
+		dir := filepath.Dir(db.settings.DBFile)
+
+		err := db.WriteBatch(numberedRecords("r", 0, 5))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//the stream holds records without anybody receiving them
+		stream := db.ReadStream()
+		waitForStreams()
+
+		for i := 0; i < compactions; i++ {
+			err = db.Compact()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = db.Write("r5")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stream.Close()
+		db.Close()
+
+		reopened, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		records := readAll(t, reopened)
+		reopened.Close()
+
+		if reflect.DeepEqual(records, numberedRecords("r", 0, 6)) == false {
+			t.Fatalf("%d compactions: records are %v", compactions, records)
+		}
+	}
+}
+
+func TestStreamReceivedRecordsStayDeliveredAfterGC(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	err := db.WriteBatch(numberedRecords("r", 0, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := db.ReadStream()
+	for i := 0; i < 3; i++ {
+		select {
+		case record := <-stream.Stream():
+			if record != fmt.Sprintf("r%d", i) {
+				t.Fatalf("received %s", record)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no record received")
+		}
+	}
+	waitForStreams()
+
+	err = db.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream.Close()
+
+	records := readAll(t, db)
+	if reflect.DeepEqual(records, numberedRecords("r", 3, 10)) == false {
+		t.Fatalf("records are %v", records)
+	}
+}
+
+func TestTypedStreamRecordsRestoredInPlaceAfterGC(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	queue := CreateTypedQueue(db, nil)
+	for i := 0; i < 5; i++ {
+		err := queue.Put(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream := queue.ReadStream(0)
+	waitForStreams()
+
+	err := db.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream.Close()
+
+	records := readAll(t, db)
+	if reflect.DeepEqual(records, numberedRecords("", 0, 5)) == false {
+		t.Fatalf("records are %v", records)
+	}
+}
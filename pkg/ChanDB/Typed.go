@@ -0,0 +1,238 @@
+package ChanDB
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+/**
+TypedQueue stores values in a Database using a Codec, so the callers do not have to marshal the values themselves
+*/
+type TypedQueue struct {
+	db    Database
+	codec Codec
+}
+
+/**
+Returned when a record can not be decoded, the record has already been removed from the database and
+Payload holds it as it was stored
+*/
+type DecodeError struct {
+	Payload string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return "failed to decode the record: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+/**
+Value received from a TypedStream, Err is a *DecodeError when the record could not be decoded
+*/
+type TypedRecord struct {
+	Value interface{}
+	Err   error
+}
+
+/**
+Wraps the database with the given codec, JSONCodec is used when the codec is nil
+*/
+func CreateTypedQueue(db Database, codec Codec) *TypedQueue {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+
+	return &TypedQueue{
+		db:    db,
+		codec: codec,
+	}
+}
+
+/* Encodes the value and writes it to the database */
+func (q *TypedQueue) Put(v interface{}) error {
+	payload, err := marshalPayload(q.codec, v)
+	if err != nil {
+		return err
+	}
+
+	return q.db.Write(payload)
+}
+
+/* Reads the next record and decodes it into v, v has to be a pointer. Returns ErrEmpty when the database is empty */
+func (q *TypedQueue) Get(v interface{}) error {
+	payload, err := q.db.Read()
+	if err != nil {
+		return err
+	}
+
+	err = q.codec.Unmarshal(payload, v)
+	if err != nil {
+		return &DecodeError{Payload: payload, Err: err}
+	}
+
+	return nil
+}
+
+func (q *TypedQueue) Length() int64 {
+	return q.db.Length()
+}
+
+/**
+Opens a stream of decoded values, the values have the type of the given example value: ReadStream(&Order{})
+delivers *Order values and ReadStream(Order{}) delivers Order values. With a nil example the values are decoded
+into an interface{}, the JSON objects are delivered as map[string]interface{} values then
+*/
+func (q *TypedQueue) ReadStream(example interface{}) *TypedStream {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	valueType := reflect.TypeOf(example)
+	if valueType == nil {
+		valueType = reflect.TypeOf((*interface{})(nil)).Elem()
+	}
+
+	instance := &TypedStream{
+		queue:     q,
+		valueType: valueType,
+		out:       make(chan TypedRecord),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan bool),
+		closeLock: &sync.Mutex{},
+		isOpen:    true,
+	}
+
+	//embedded databases restore the records that were not received to their place in the database
+	if m, ok := q.db.(*manager); ok {
+		instance.manager = m
+		if m.registerStream(instance) == false {
+			instance.isOpen = false
+			close(instance.out)
+			return instance
+		}
+	} else {
+		instance.stream = q.db.ReadStream()
+	}
+
+	go instance.streamRoutine()
+
+	return instance
+}
+
+type TypedStream struct {
+	queue     *TypedQueue
+	valueType reflect.Type
+	manager   *manager
+	stream    Stream
+	out       chan TypedRecord
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan bool
+	closeLock *sync.Mutex
+	isOpen    bool
+	//set by the stream routine when a record that was not received could not be written back
+	writeBackErr error
+}
+
+func (s *TypedStream) streamRoutine() {
+	defer close(s.done)
+	defer close(s.out)
+	if s.manager != nil {
+		defer s.manager.streamStopped()
+	}
+
+	for {
+		record, ok := s.next()
+		if ok == false {
+			return
+		}
+
+		select {
+		case s.out <- s.decode(record.payload):
+			if s.manager != nil {
+				s.manager.streamDelivered(record)
+			}
+		case <-s.ctx.Done():
+			//nobody received the record, it goes back to the database
+			s.giveBack(record)
+			return
+		}
+	}
+}
+
+func (s *TypedStream) next() (storedRecord, bool) {
+	if s.manager != nil {
+		return s.manager.nextStreamRecord(s.ctx)
+	}
+
+	select {
+	case <-s.ctx.Done():
+		return storedRecord{}, false
+	case payload, ok := <-s.stream.Stream():
+		//the stream is closed when the database is closed
+		return storedRecord{payload: payload}, ok
+	}
+}
+
+func (s *TypedStream) giveBack(record storedRecord) {
+	if s.manager != nil {
+		s.manager.restoreRecord(record)
+		return
+	}
+
+	s.writeBackErr = s.queue.db.Write(record.payload)
+}
+
+func (s *TypedStream) decode(payload string) TypedRecord {
+	pointer := s.valueType.Kind() == reflect.Ptr
+
+	valueType := s.valueType
+	if pointer {
+		valueType = s.valueType.Elem()
+	}
+
+	value := reflect.New(valueType)
+	err := s.queue.codec.Unmarshal(payload, value.Interface())
+	if err != nil {
+		return TypedRecord{Err: &DecodeError{Payload: payload, Err: err}}
+	}
+
+	if pointer {
+		return TypedRecord{Value: value.Interface()}
+	}
+
+	return TypedRecord{Value: value.Elem().Interface()}
+}
+
+func (s *TypedStream) Stream() <-chan TypedRecord {
+	return s.out
+}
+
+/* Closes the stream, the records that have not been received are kept in the database */
+func (s *TypedStream) Close() error {
+	s.closeLock.Lock()
+	defer s.closeLock.Unlock()
+
+	if s.isOpen == false {
+		return nil
+	}
+
+	s.isOpen = false
+	s.cancel()
+	<-s.done
+
+	if s.stream == nil {
+		return nil
+	}
+
+	err := s.stream.Close()
+	if s.writeBackErr != nil {
+		return s.writeBackErr
+	}
+
+	return err
+}
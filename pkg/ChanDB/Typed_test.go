@@ -0,0 +1,139 @@
+package ChanDB
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type typedOrder struct {
+	ID     int
+	Amount float64
+}
+
+func openTestDatabase(t *testing.T, options ...Option) (*manager, func()) {
+	dir, err := ioutil.TempDir("", "chandb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(dir, options...)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+type typedShipment struct {
+	Order typedOrder
+	Note  string
+	Tags  []string
+}
+
+func TestTypedQueueCodecs(t *testing.T) {
+	codecs := map[string]Codec{
+		"json": JSONCodec{},
+		"gob":  GobCodec{},
+	}
+
+	for name, codec := range codecs {
+		db, cleanup := openTestDatabase(t)
+
+		queue := CreateTypedQueue(db, codec)
+		sent := typedShipment{Order: typedOrder{ID: 7, Amount: 12.25}, Note: "fragile\nhandle with care", Tags: []string{"a", "b"}}
+		err := queue.Put(sent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//a record that was not written with the codec
+		err = db.Write("not encoded")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		received := typedShipment{}
+		err = queue.Get(&received)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if reflect.DeepEqual(received, sent) == false {
+			t.Fatalf("%s: received %#v", name, received)
+		}
+
+		var decodeErr *DecodeError
+		err = queue.Get(&received)
+		if errors.As(err, &decodeErr) == false || decodeErr.Payload != "not encoded" {
+			t.Fatalf("%s: Get() of a record not written with the codec returned %v", name, err)
+		}
+
+		if err := queue.Get(&received); errors.Is(err, ErrEmpty) == false {
+			t.Fatalf("%s: Get() of an empty queue returned %v", name, err)
+		}
+
+		cleanup()
+	}
+}
+
+func receiveTyped(t *testing.T, stream *TypedStream) TypedRecord {
+	select {
+	case record, ok := <-stream.Stream():
+		if ok == false {
+			t.Fatal("the stream has been closed")
+		}
+		return record
+	case <-time.After(5 * time.Second):
+		t.Fatal("no record received")
+	}
+
+	return TypedRecord{}
+}
+
+func TestTypedStreamExamples(t *testing.T) {
+	db, cleanup := openTestDatabase(t)
+	defer cleanup()
+
+	queue := CreateTypedQueue(db, nil)
+	for i := 1; i <= 3; i++ {
+		err := queue.Put(typedOrder{ID: i, Amount: 9.5})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream := queue.ReadStream(&typedOrder{})
+	record := receiveTyped(t, stream)
+	if order, ok := record.Value.(*typedOrder); ok == false || order.ID != 1 {
+		t.Fatalf("expected *typedOrder 1, got %#v", record.Value)
+	}
+	stream.Close()
+
+	stream = queue.ReadStream(typedOrder{})
+	record = receiveTyped(t, stream)
+	if order, ok := record.Value.(typedOrder); ok == false || order.ID != 2 {
+		t.Fatalf("expected typedOrder 2, got %#v", record.Value)
+	}
+	stream.Close()
+
+	//a nil example decodes into interface{}
+	stream = queue.ReadStream(nil)
+	defer stream.Close()
+
+	record = receiveTyped(t, stream)
+	if record.Err != nil {
+		t.Fatal(record.Err)
+	}
+
+	value, ok := record.Value.(map[string]interface{})
+	if ok == false || value["ID"] != float64(3) {
+		t.Fatalf("expected a map with ID 3, got %#v", record.Value)
+	}
+}
@@ -0,0 +1,280 @@
+package Client
+
+import (
+	"context"
+	"errors"
+	"github.com/theorx/ChanDB/internal/Protocol"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"io"
+	"sync"
+	"time"
+)
+
+type Settings struct {
+	/**
+	Number of idle connections kept open to the server, default is 4
+	*/
+	PoolSize int
+	/**
+	How many times a request is tried again after a connection error, default is 2. -1 disables the retries. The
+	reads, writes and truncates are tried again only when they could not be sent, once sent they may have been
+	executed and they are never sent twice
+	*/
+	Retries int
+	/**
+	Wait before the first retry, it is doubled for every following retry. Default is 100 milliseconds
+	*/
+	RetryBackoff time.Duration
+	/**
+	Default is 5 seconds
+	*/
+	DialTimeout time.Duration
+	/**
+	Receives the read failures of the streams and the records a closed stream could not write back
+	*/
+	Logger ChanDB.Logger
+}
+
+const (
+	defaultPoolSize     = 4
+	defaultRetries      = 2
+	defaultRetryBackoff = 100 * time.Millisecond
+	defaultDialTimeout  = 5 * time.Second
+	//the server shortens the wait to its MaxWait, the read is sent again until the context is done
+	maxReadWait = time.Hour
+)
+
+/**
+Client is a ChanDB.Database stored on a server of the github.com/theorx/ChanDB/pkg/Remote package, so the code
+using a database can switch between an embedded and a remote database by changing the constructor
+*/
+type Client struct {
+	pool     *pool
+	settings Settings
+	lock     *sync.Mutex
+	//the streams and the subscriptions, they are closed with the client
+	streams []io.Closer
+	closed  bool
+}
+
+var _ ChanDB.Database = &Client{}
+
+/**
+Connects to the server at the address, fails when the server can not be reached
+*/
+func CreateClient(address string, settings Settings) (*Client, error) {
+	if settings.PoolSize <= 0 {
+		settings.PoolSize = defaultPoolSize
+	}
+
+	if settings.Retries < 0 {
+		settings.Retries = 0
+	} else if settings.Retries == 0 {
+		settings.Retries = defaultRetries
+	}
+
+	if settings.RetryBackoff <= 0 {
+		settings.RetryBackoff = defaultRetryBackoff
+	}
+
+	if settings.DialTimeout <= 0 {
+		settings.DialTimeout = defaultDialTimeout
+	}
+
+	client := &Client{
+		pool:     createPool(address, settings),
+		settings: settings,
+		lock:     &sync.Mutex{},
+		streams:  make([]io.Closer, 0),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), settings.DialTimeout)
+	defer cancel()
+
+	err := client.Ping(ctx)
+	if err != nil {
+		client.pool.close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.request(ctx, Protocol.OpPing, nil)
+	return err
+}
+
+func (c *Client) Write(payload string) error {
+	return c.WriteBatchContext(context.Background(), []string{payload})
+}
+
+func (c *Client) WriteContext(ctx context.Context, payload string) error {
+	return c.WriteBatchContext(ctx, []string{payload})
+}
+
+func (c *Client) WriteBatch(payloads []string) error {
+	return c.WriteBatchContext(context.Background(), payloads)
+}
+
+/**
+The records are written as one batch. When the server fails to store one of them the records before it stay
+stored, and when the response is lost none, some or all of them may have been stored
+*/
+func (c *Client) WriteBatchContext(ctx context.Context, payloads []string) error {
+	_, err := c.request(ctx, Protocol.OpWrite, Protocol.EncodeStrings(payloads))
+	return err
+}
+
+/**
+Returns ChanDB.ErrEmpty when the database is empty
+*/
+func (c *Client) Read() (string, error) {
+	record, err := c.request(context.Background(), Protocol.OpRead, Protocol.EncodeInt(0))
+	return string(record), err
+}
+
+/**
+Waits for a record until the context is done, returns ctx.Err() when the context is done first
+*/
+func (c *Client) ReadContext(ctx context.Context) (string, error) {
+	for {
+		wait := maxReadWait
+		if deadline, ok := ctx.Deadline(); ok {
+			wait = time.Until(deadline)
+		}
+
+		if wait <= 0 {
+			return "", context.DeadlineExceeded
+		}
+
+		record, err := c.request(ctx, Protocol.OpRead, Protocol.EncodeInt(int64(wait)))
+		if err == ChanDB.ErrEmpty {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			continue
+		}
+
+		return string(record), err
+	}
+}
+
+/**
+Returns -1 when the server can not be reached, LengthContext() returns the error
+*/
+func (c *Client) Length() int64 {
+	length, err := c.LengthContext(context.Background())
+	if err != nil {
+		return -1
+	}
+
+	return length
+}
+
+func (c *Client) LengthContext(ctx context.Context) (int64, error) {
+	response, err := c.request(ctx, Protocol.OpLength, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return Protocol.DecodeInt(response)
+}
+
+func (c *Client) Truncate() error {
+	return c.TruncateContext(context.Background())
+}
+
+func (c *Client) TruncateContext(ctx context.Context) error {
+	_, err := c.request(ctx, Protocol.OpTruncate, nil)
+	return err
+}
+
+/**
+Closes the streams, the subscriptions and the connections, the database on the server stays open
+*/
+func (c *Client) Close() error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return nil
+	}
+	c.closed = true
+	streams := c.streams
+	c.streams = nil
+	c.lock.Unlock()
+
+	for _, stream := range streams {
+		stream.Close()
+	}
+
+	c.pool.close()
+
+	return nil
+}
+
+/**
+Sends the request and returns the body of the response, the errors returned by the server are returned as
+they are. After a connection error the request is tried again when it had not been sent yet or when it does not
+take or change records, so the records are read and written at most once
+*/
+func (c *Client) request(ctx context.Context, op byte, body []byte) ([]byte, error) {
+	backoff := c.settings.RetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		status, response, sent, err := c.roundTrip(ctx, op, body)
+		if err == nil {
+			if status == Protocol.StatusOK {
+				return response, nil
+			}
+
+			return nil, Protocol.ResponseError(status, response)
+		}
+
+		if errors.Is(err, ChanDB.ErrClosed) || ctx.Err() != nil || attempt >= c.settings.Retries {
+			return nil, err
+		}
+
+		//the server may have taken the record or stored the records before the connection failed
+		if sent && repeatable(op) == false {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+//sent is false when the connection failed before the request was written to it
+func (c *Client) roundTrip(ctx context.Context, op byte, body []byte) (byte, []byte, bool, error) {
+	conn, err := c.pool.get(ctx)
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	status, response, err := conn.roundTrip(ctx, op, body)
+	if err != nil {
+		conn.close()
+		return 0, nil, true, err
+	}
+
+	c.pool.put(conn)
+
+	return status, response, true, nil
+}
+
+//the operations that give the same result when they are executed twice
+func repeatable(op byte) bool {
+	return op == Protocol.OpPing || op == Protocol.OpLength
+}
+
+func (c *Client) log(level ChanDB.Level, msg string, keyvals ...interface{}) {
+	if c.settings.Logger != nil {
+		c.settings.Logger.Log(level, msg, keyvals...)
+	}
+}
@@ -0,0 +1,259 @@
+package Client
+
+import (
+	"bufio"
+	"context"
+	"github.com/theorx/ChanDB/internal/Protocol"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"github.com/theorx/ChanDB/pkg/Remote"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+/**
+Server answering the pings and dropping the connection after reading any other request, it counts the requests
+it has read by operation
+*/
+type droppingServer struct {
+	listener net.Listener
+	lock     *sync.Mutex
+	requests map[byte]int
+}
+
+func startDroppingServer(t *testing.T) *droppingServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return serveDropping(listener)
+}
+
+func serveDropping(listener net.Listener) *droppingServer {
+	s := &droppingServer{listener: listener, lock: &sync.Mutex{}, requests: make(map[byte]int)}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *droppingServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	for {
+		op, _, err := Protocol.ReadFrame(reader)
+		if err != nil {
+			return
+		}
+
+		if op == Protocol.OpPing {
+			Protocol.WriteFrame(writer, Protocol.StatusOK, nil)
+			continue
+		}
+
+		s.lock.Lock()
+		s.requests[op]++
+		s.lock.Unlock()
+		return
+	}
+}
+
+func (s *droppingServer) received(op byte) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.requests[op]
+}
+
+func TestConsumingRequestsAreNotRetried(t *testing.T) {
+	server := startDroppingServer(t)
+	defer server.listener.Close()
+
+	client, err := CreateClient(server.listener.Addr().String(), Settings{Retries: 3, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	_, err = client.Read()
+	if err == nil {
+		t.Fatal("the read did not fail")
+	}
+
+	err = client.Write("record")
+	if err == nil {
+		t.Fatal("the write did not fail")
+	}
+
+	err = client.Truncate()
+	if err == nil {
+		t.Fatal("the truncate did not fail")
+	}
+
+	if client.Length() != -1 {
+		t.Fatal("the length did not fail")
+	}
+
+	for op, expected := range map[byte]int{Protocol.OpRead: 1, Protocol.OpWrite: 1, Protocol.OpTruncate: 1, Protocol.OpLength: 4} {
+		if received := server.received(op); received != expected {
+			t.Fatalf("operation %d was sent %d times, expected %d", op, received, expected)
+		}
+	}
+}
+
+//the requests that could not be sent are tried again, the server is reached once it is listening again
+func TestRequestRetriedWhenNotSent(t *testing.T) {
+	server := startDroppingServer(t)
+	address := server.listener.Addr().String()
+
+	client, err := CreateClient(address, Settings{Retries: 5, RetryBackoff: 20 * time.Millisecond, PoolSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	//the connection used by the ping is closed, the next request dials
+	client.pool.close()
+	client.pool = createPool(address, client.settings)
+	server.listener.Close()
+
+	restarted := make(chan *droppingServer, 1)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			t.Error(err)
+			close(restarted)
+			return
+		}
+		restarted <- serveDropping(listener)
+	}()
+
+	_, err = client.Read()
+	if err == nil {
+		t.Fatal("the read did not fail")
+	}
+
+	restartedServer, ok := <-restarted
+	if ok == false {
+		return
+	}
+	defer restartedServer.listener.Close()
+
+	if received := restartedServer.received(Protocol.OpRead); received != 1 {
+		t.Fatalf("the read was received %d times", received)
+	}
+}
+
+//the idle connections closed by a restarted server are not used for the requests, so the writes are not lost
+func TestIdleConnectionsClosedByServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chandb-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ChanDB.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+
+	server := Remote.CreateServer(db, Remote.Settings{})
+	go server.Serve(listener)
+
+	client, err := CreateClient(address, Settings{Retries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Write("before")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server = Remote.CreateServer(db, Remote.Settings{})
+	go server.Serve(listener)
+	defer server.Close()
+
+	err = client.Write("after")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.Length() != 2 {
+		t.Fatalf("the database holds %d records", db.Length())
+	}
+}
+
+//the reads are not sent again, a read cut off by the deadline of its connection reports the deadline of the context
+func TestReadContextDeadline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chandb-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ChanDB.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := Remote.CreateServer(db, Remote.Settings{})
+	go server.Serve(listener)
+	defer server.Close()
+
+	client, err := CreateClient(listener.Addr().String(), Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err = client.ReadContext(ctx)
+		cancel()
+
+		if err != context.DeadlineExceeded {
+			t.Fatalf("read %d returned %v", i, err)
+		}
+	}
+}
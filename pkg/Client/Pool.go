@@ -0,0 +1,177 @@
+package Client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/theorx/ChanDB/internal/Protocol"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"net"
+	"sync"
+	"time"
+)
+
+//a deadline in the past, it interrupts the reads and writes of the connection
+var interrupted = time.Unix(1, 0)
+
+type connection struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	//result of reading the connection while it is idle
+	watched chan error
+}
+
+/**
+Sends a request and reads its response. The connection can not be used anymore when an error is returned, the
+request may or may not have been executed by the server
+*/
+func (c *connection) roundTrip(ctx context.Context, op byte, body []byte) (byte, []byte, error) {
+	deadline, hasDeadline := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+
+	//the context is done while waiting for the response
+	finished := make(chan bool)
+	watching := make(chan bool)
+	go func() {
+		defer close(watching)
+
+		select {
+		case <-ctx.Done():
+			c.conn.SetDeadline(interrupted)
+		case <-finished:
+		}
+	}()
+
+	err := Protocol.WriteFrame(c.writer, op, body)
+	var status byte
+	var response []byte
+	if err == nil {
+		status, response, err = Protocol.ReadFrame(c.reader)
+	}
+
+	close(finished)
+	<-watching
+
+	if err != nil && ctx.Err() != nil {
+		return 0, nil, ctx.Err()
+	}
+
+	//the deadline of the connection can pass before the context is done
+	if err != nil && hasDeadline && time.Now().Before(deadline) == false {
+		return 0, nil, context.DeadlineExceeded
+	}
+
+	return status, response, err
+}
+
+/**
+The server sends nothing on an idle connection, it is read while idle to notice when the server closes it, such as
+when the server is restarted. A request sent on such a connection would fail after it has been sent
+*/
+func (c *connection) watch() {
+	c.watched = make(chan error, 1)
+
+	go func() {
+		_, err := c.reader.Peek(1)
+		c.watched <- err
+	}()
+}
+
+//stops the reading started by watch(), returns false when the connection can not be used anymore
+func (c *connection) unwatch() bool {
+	c.conn.SetReadDeadline(interrupted)
+	err := <-c.watched
+	c.conn.SetReadDeadline(time.Time{})
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (c *connection) close() {
+	c.conn.Close()
+}
+
+/**
+Idle connections to the server, a connection is taken for each request and put back once the response has been
+read. The number of open connections is not limited, only PoolSize of them are kept open while idle
+*/
+type pool struct {
+	address  string
+	settings Settings
+	lock     *sync.Mutex
+	idle     []*connection
+	closed   bool
+}
+
+func createPool(address string, settings Settings) *pool {
+	return &pool{
+		address:  address,
+		settings: settings,
+		lock:     &sync.Mutex{},
+		idle:     make([]*connection, 0, settings.PoolSize),
+	}
+}
+
+func (p *pool) get(ctx context.Context) (*connection, error) {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil, ChanDB.ErrClosed
+	}
+
+	for len(p.idle) > 0 {
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.lock.Unlock()
+
+		if c.unwatch() {
+			return c, nil
+		}
+		c.close()
+
+		p.lock.Lock()
+	}
+	p.lock.Unlock()
+
+	return p.dial(ctx)
+}
+
+//opens a new connection, it is not taken from the idle connections
+func (p *pool) dial(ctx context.Context) (*connection, error) {
+	dialer := &net.Dialer{Timeout: p.settings.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connection{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}, nil
+}
+
+func (p *pool) put(c *connection) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed || len(p.idle) >= p.settings.PoolSize {
+		c.close()
+		return
+	}
+
+	c.watch()
+	p.idle = append(p.idle, c)
+}
+
+func (p *pool) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.closed = true
+	for _, c := range p.idle {
+		c.close()
+	}
+	p.idle = nil
+}
@@ -0,0 +1,118 @@
+package Client
+
+import (
+	"context"
+	"errors"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"sync"
+	"time"
+)
+
+type stream struct {
+	client    *Client
+	out       chan string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan bool
+	isOpen    bool
+	closeLock *sync.Mutex
+}
+
+/**
+Opens a stream reading the records from the server. The stream holds one record that has been read from the
+server until it is received from the channel, when the stream is closed before that the record is written back
+to the end of the database, so its place in the order changes
+*/
+func (c *Client) ReadStream() ChanDB.Stream {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &stream{
+		client:    c,
+		out:       make(chan string),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan bool),
+		isOpen:    true,
+		closeLock: &sync.Mutex{},
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	//the stream of a closed client delivers nothing
+	if c.closed {
+		s.isOpen = false
+		close(s.out)
+		return s
+	}
+
+	c.streams = append(c.streams, s)
+	go s.streamRoutine()
+
+	return s
+}
+
+func (s *stream) streamRoutine() {
+	defer close(s.done)
+
+	for {
+		record, err := s.client.ReadContext(s.ctx)
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+
+			s.client.log(ChanDB.LevelError, "stream failed reading from the server", "error", err)
+
+			//the database on the server has been closed
+			if errors.Is(err, ChanDB.ErrClosed) {
+				return
+			}
+
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(s.client.settings.RetryBackoff):
+			}
+			continue
+		}
+
+		select {
+		case s.out <- record:
+		case <-s.ctx.Done():
+			s.writeBack(record)
+			return
+		}
+	}
+}
+
+func (s *stream) writeBack(record string) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.settings.DialTimeout)
+	defer cancel()
+
+	err := s.client.WriteContext(ctx, record)
+	if err != nil {
+		s.client.log(ChanDB.LevelError, "failed writing back a record, the record is lost", "payload", record, "error", err)
+	}
+}
+
+func (s *stream) Close() error {
+	s.closeLock.Lock()
+	defer s.closeLock.Unlock()
+
+	if s.isOpen == false {
+		return nil
+	}
+
+	s.isOpen = false
+	//stop the reading routine and wait until it has written back the record it was holding
+	s.cancel()
+	<-s.done
+	close(s.out)
+
+	return nil
+}
+
+func (s *stream) Stream() <-chan string {
+	return s.out
+}
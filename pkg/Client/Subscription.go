@@ -0,0 +1,275 @@
+package Client
+
+import (
+	"context"
+	"github.com/theorx/ChanDB/internal/Protocol"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"sync"
+	"time"
+)
+
+type SubscriptionSettings struct {
+	/**
+	Number of records the server sends before they are acknowledged, default is 64. Acknowledging the records
+	lets the server send more of them, so a slow consumer is not sent more records than it can take
+	*/
+	Window int
+}
+
+const defaultWindow = 64
+
+type Message struct {
+	//identifies the record for Ack(), the ids are only unique within the subscription
+	ID      uint64
+	Payload string
+}
+
+/**
+Subscription streams the records of the database over a connection of its own. The server sends at most Window
+records that have not been acknowledged with Ack(). The records stay in the database until they are acknowledged,
+the ones that have not been acknowledged when the connection ends are restored to their places at the head of the
+database, so a record is delivered at least once unless the server crashes. Records can be written with Produce()
+over the same connection without waiting for each write separately
+*/
+type Subscription struct {
+	conn      *connection
+	window    int64
+	messages  chan Message
+	lock      *sync.Mutex
+	writeLock *sync.Mutex
+	//acknowledged records whose credits have not been returned to the server yet
+	acknowledged int64
+	nextID       uint64
+	producing    map[uint64]chan error
+	done         chan bool
+	err          error
+	closed       bool
+}
+
+/**
+Opens a subscription on a new connection, the connection is not taken from the pool and it is not retried. The
+subscription ends when it is closed or when the connection fails, Err() tells why
+*/
+func (c *Client) Subscribe(ctx context.Context, settings SubscriptionSettings) (*Subscription, error) {
+	if settings.Window <= 0 {
+		settings.Window = defaultWindow
+	}
+
+	c.lock.Lock()
+	closed := c.closed
+	c.lock.Unlock()
+	if closed {
+		return nil, ChanDB.ErrClosed
+	}
+
+	conn, err := c.pool.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status, response, err := conn.roundTrip(ctx, Protocol.OpSubscribe, Protocol.EncodeInt(int64(settings.Window)))
+	if err == nil && status != Protocol.StatusOK {
+		err = Protocol.ResponseError(status, response)
+	}
+
+	if err != nil {
+		conn.close()
+		return nil, err
+	}
+
+	//the records are waited for without a deadline
+	conn.conn.SetDeadline(time.Time{})
+
+	s := &Subscription{
+		conn:      conn,
+		window:    int64(settings.Window),
+		messages:  make(chan Message, settings.Window),
+		lock:      &sync.Mutex{},
+		writeLock: &sync.Mutex{},
+		producing: make(map[uint64]chan error),
+		done:      make(chan bool),
+	}
+
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		conn.close()
+		return nil, ChanDB.ErrClosed
+	}
+	c.streams = append(c.streams, s)
+	c.lock.Unlock()
+
+	go s.receive()
+
+	return s, nil
+}
+
+/**
+The channel is closed when the subscription ends. It has room for Window records, so the subscription never
+waits for the receiver
+*/
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+/**
+Tells the server that the records have been processed, the server removes them from the database. The credits for
+sending more records are returned to the server once half of the window has been acknowledged
+*/
+func (s *Subscription) Ack(ids ...uint64) error {
+	err := s.write(Protocol.OpAck, Protocol.EncodeIDs(ids))
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	s.acknowledged += int64(len(ids))
+	credits := int64(0)
+	if s.acknowledged*2 >= s.window {
+		credits = s.acknowledged
+		s.acknowledged = 0
+	}
+	s.lock.Unlock()
+
+	if credits == 0 {
+		return nil
+	}
+
+	return s.write(Protocol.OpCredit, Protocol.EncodeInt(credits))
+}
+
+/**
+Writes the records as one batch and waits for the server to store them. Several goroutines can produce at the
+same time, their writes are sent over the connection without waiting for each other
+*/
+func (s *Subscription) Produce(ctx context.Context, payloads []string) error {
+	s.lock.Lock()
+	if s.err != nil {
+		s.lock.Unlock()
+		return s.err
+	}
+	s.nextID++
+	id := s.nextID
+	stored := make(chan error, 1)
+	s.producing[id] = stored
+	s.lock.Unlock()
+
+	err := s.write(Protocol.OpProduce, Protocol.EncodeWithID(id, Protocol.EncodeStrings(payloads)))
+	if err != nil {
+		s.forget(id)
+		return err
+	}
+
+	select {
+	case err = <-stored:
+		return err
+	case <-ctx.Done():
+		s.forget(id)
+		return ctx.Err()
+	}
+}
+
+/**
+Returns the error that ended the subscription, nil while it is active and ChanDB.ErrClosed once it has been closed
+*/
+func (s *Subscription) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.err
+}
+
+/**
+Closes the connection, the server restores the records that have not been acknowledged
+*/
+func (s *Subscription) Close() error {
+	s.lock.Lock()
+	s.closed = true
+	s.lock.Unlock()
+
+	s.conn.close()
+	<-s.done
+
+	return nil
+}
+
+func (s *Subscription) receive() {
+	defer close(s.done)
+
+	for {
+		status, body, err := Protocol.ReadFrame(s.conn.reader)
+		if err != nil {
+			s.finish(err)
+			return
+		}
+
+		id, payload, err := Protocol.DecodeWithID(body)
+		if err != nil {
+			s.finish(Protocol.ResponseError(status, body))
+			return
+		}
+
+		if status == Protocol.StatusRecord {
+			s.messages <- Message{ID: id, Payload: string(payload)}
+			continue
+		}
+
+		//the response to a produced batch
+		if status == Protocol.StatusOK {
+			err = nil
+		} else {
+			err = Protocol.ResponseError(status, payload)
+		}
+
+		s.lock.Lock()
+		stored := s.producing[id]
+		delete(s.producing, id)
+		s.lock.Unlock()
+
+		if stored != nil {
+			stored <- err
+		}
+	}
+}
+
+//fails the writes waiting for their response and closes the messages channel
+func (s *Subscription) finish(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		err = ChanDB.ErrClosed
+	}
+
+	s.err = err
+	for id, stored := range s.producing {
+		stored <- err
+		delete(s.producing, id)
+	}
+
+	close(s.messages)
+}
+
+func (s *Subscription) forget(id uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.producing, id)
+}
+
+func (s *Subscription) write(op byte, body []byte) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	err := Protocol.WriteFrame(s.conn.writer, op, body)
+	if err != nil {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if s.err != nil {
+			return s.err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,108 @@
+package Metrics
+
+import (
+	"bufio"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+/**
+Database the metrics are collected from, the databases created by ChanDB implement it
+*/
+type Source interface {
+	Stats() ChanDB.Stats
+	OverflowCounters() ChanDB.OverflowCounters
+	Latencies() ChanDB.Latencies
+}
+
+/**
+Exporter is an http.Handler serving the metrics of the registered databases in the Prometheus text exposition
+format. Each database is labeled with the name it has been registered with: chandb_records{queue="orders"} 10
+*/
+type Exporter struct {
+	lock    *sync.RWMutex
+	sources map[string]Source
+}
+
+func CreateExporter() *Exporter {
+	return &Exporter{
+		lock:    &sync.RWMutex{},
+		sources: make(map[string]Source),
+	}
+}
+
+/* Adds the database to the exported metrics, a database registered earlier with the same name is replaced */
+func (e *Exporter) Register(queue string, source Source) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.sources[queue] = source
+}
+
+func (e *Exporter) Unregister(queue string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	delete(e.sources, queue)
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	out := bufio.NewWriter(w)
+	e.write(createTextWriter(out))
+	out.Flush()
+}
+
+func (e *Exporter) write(writer *textWriter) {
+	e.lock.RLock()
+	queues := make([]string, 0, len(e.sources))
+	for queue := range e.sources {
+		queues = append(queues, queue)
+	}
+	sources := make([]Source, len(queues))
+	sort.Strings(queues)
+	for i, queue := range queues {
+		sources[i] = e.sources[queue]
+	}
+	e.lock.RUnlock()
+
+	//all of the samples of a metric have to be written together, so the values are collected first
+	snapshots := make([]snapshot, len(queues))
+	for i, source := range sources {
+		snapshots[i] = snapshot{
+			queue:     queues[i],
+			stats:     source.Stats(),
+			overflow:  source.OverflowCounters(),
+			latencies: source.Latencies(),
+		}
+	}
+
+	for _, metric := range metrics {
+		writer.header(metric.name, metric.help, metric.kind)
+		for _, s := range snapshots {
+			metric.write(writer, metric.name, s)
+		}
+	}
+}
+
+type snapshot struct {
+	queue     string
+	stats     ChanDB.Stats
+	overflow  ChanDB.OverflowCounters
+	latencies ChanDB.Latencies
+}
@@ -0,0 +1,131 @@
+package Metrics
+
+import (
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//returns the lines of the response that are samples of the metric, without the comments
+func samples(body string, name string) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, name+"{") || strings.HasPrefix(line, name+" ") {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+func sampleValue(t *testing.T, line string) float64 {
+	value, err := strconv.ParseFloat(line[strings.LastIndex(line, " ")+1:], 64)
+	if err != nil {
+		t.Fatalf("invalid sample %q", line)
+	}
+
+	return value
+}
+
+func TestExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chandb-metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ChanDB.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 5; i++ {
+		err = db.Write("record")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = db.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exporter := CreateExporter()
+	exporter.Register("orders\"\\\n", db)
+
+	response := httptest.NewRecorder()
+	exporter.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if response.Code != http.StatusOK || response.Header().Get("Content-Type") != contentType {
+		t.Fatalf("the response has status %d and type %q", response.Code, response.Header().Get("Content-Type"))
+	}
+
+	body := response.Body.String()
+	queue := `queue="orders\"\\\n"`
+
+	for _, metric := range metrics {
+		if strings.Contains(body, "# TYPE "+metric.name+" "+metric.kind+"\n") == false {
+			t.Fatalf("%s is not exported", metric.name)
+		}
+	}
+
+	expected := map[string]float64{
+		"chandb_records{" + queue + "}":      4,
+		"chandb_writes_total{" + queue + "}": 5,
+		"chandb_reads_total{" + queue + "}":  1,
+	}
+	for sample, value := range expected {
+		lines := samples(body, sample[:strings.Index(sample, "{")])
+		if len(lines) != 1 || strings.HasPrefix(lines[0], sample+" ") == false || sampleValue(t, lines[0]) != value {
+			t.Fatalf("expected %s %v, the samples are %q", sample, value, lines)
+		}
+	}
+
+	//the buckets are cumulative and the last one counts every write
+	buckets := samples(body, "chandb_write_duration_seconds_bucket")
+	previous := float64(0)
+	for _, line := range buckets {
+		if strings.Contains(line, queue) == false {
+			t.Fatalf("the bucket %q is not labeled with the queue", line)
+		}
+
+		value := sampleValue(t, line)
+		if value < previous {
+			t.Fatalf("the buckets are not cumulative: %q", buckets)
+		}
+		previous = value
+	}
+
+	last := buckets[len(buckets)-1]
+	if strings.Contains(last, `le="+Inf"`) == false {
+		t.Fatalf("the last bucket is %q", last)
+	}
+
+	count := samples(body, "chandb_write_duration_seconds_count")
+	if len(count) != 1 || sampleValue(t, count[0]) != sampleValue(t, last) || sampleValue(t, last) != 5 {
+		t.Fatalf("the +Inf bucket is %q, the count is %q", last, count)
+	}
+}
+
+func TestExporterMethods(t *testing.T) {
+	exporter := CreateExporter()
+
+	response := httptest.NewRecorder()
+	exporter.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if response.Code != http.StatusMethodNotAllowed || response.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("POST returned %d", response.Code)
+	}
+
+	response = httptest.NewRecorder()
+	exporter.ServeHTTP(response, httptest.NewRequest(http.MethodHead, "/metrics", nil))
+	if response.Code != http.StatusOK || response.Body.Len() != 0 {
+		t.Fatalf("HEAD returned %d with %d bytes", response.Code, response.Body.Len())
+	}
+}
@@ -0,0 +1,125 @@
+package Metrics
+
+import (
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+)
+
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	write func(w *textWriter, name string, s snapshot)
+}
+
+//metrics exported for every registered database, in the order they are written
+var metrics = []metric{
+	gauge("chandb_records", "Number of active records.", func(s snapshot) float64 {
+		return float64(s.stats.Records)
+	}),
+	gauge("chandb_record_bytes", "Bytes the active records take up in the files.", func(s snapshot) float64 {
+		return float64(s.stats.Bytes)
+	}),
+	{
+		name: "chandb_file_bytes",
+		help: "Size of the database file, including the header.",
+		kind: "gauge",
+		write: func(w *textWriter, name string, s snapshot) {
+			w.sample(name, queueLabels(s, label{"file", "db"}), float64(s.stats.DBFileBytes))
+			w.sample(name, queueLabels(s, label{"file", "write"}), float64(s.stats.WriteOnlyFileBytes))
+			w.sample(name, queueLabels(s, label{"file", "gc"}), float64(s.stats.GCFileBytes))
+		},
+	},
+	gauge("chandb_dead_bytes", "Bytes of the read records waiting for garbage collection.", func(s snapshot) float64 {
+		return float64(s.stats.DeadBytes)
+	}),
+	counter("chandb_writes_total", "Records written since the database was opened.", func(s snapshot) float64 {
+		return float64(s.stats.Writes)
+	}),
+	counter("chandb_reads_total", "Records read since the database was opened.", func(s snapshot) float64 {
+		return float64(s.stats.Reads)
+	}),
+	gauge("chandb_oldest_record_age_seconds", "Time since the oldest active record was written.", func(s snapshot) float64 {
+		return s.stats.OldestRecordAge.Seconds()
+	}),
+	gauge("chandb_streams", "Number of open streams.", func(s snapshot) float64 {
+		return float64(s.stats.Streams)
+	}),
+	gauge("chandb_last_sync_timestamp_seconds", "Unix time of the last sync call, 0 before the first one.", func(s snapshot) float64 {
+		if s.stats.LastSync.IsZero() {
+			return 0
+		}
+		return float64(s.stats.LastSync.UnixNano()) / 1e9
+	}),
+	gauge("chandb_gc_in_progress", "1 while garbage collection is running.", func(s snapshot) float64 {
+		if s.stats.GarbageCollecting {
+			return 1
+		}
+		return 0
+	}),
+	counter("chandb_overflow_rejected_total", "Writes rejected because the database was full.", func(s snapshot) float64 {
+		return float64(s.overflow.Rejected)
+	}),
+	counter("chandb_overflow_blocked_total", "Writes that waited for free space.", func(s snapshot) float64 {
+		return float64(s.overflow.Blocked)
+	}),
+	counter("chandb_overflow_dropped_total", "Records dropped to make room for new records.", func(s snapshot) float64 {
+		return float64(s.overflow.Dropped)
+	}),
+	histogram("chandb_write_duration_seconds", "Duration of the writes.", func(s snapshot) ChanDB.Histogram {
+		return s.latencies.Write
+	}),
+	histogram("chandb_read_duration_seconds", "Duration of the reads.", func(s snapshot) ChanDB.Histogram {
+		return s.latencies.Read
+	}),
+	histogram("chandb_sync_duration_seconds", "Duration of the sync calls.", func(s snapshot) ChanDB.Histogram {
+		return s.latencies.Sync
+	}),
+	histogram("chandb_gc_duration_seconds", "Duration of the garbage collection runs.", func(s snapshot) ChanDB.Histogram {
+		return s.latencies.GarbageCollection
+	}),
+}
+
+func queueLabels(s snapshot, labels ...label) []label {
+	return append([]label{{"queue", s.queue}}, labels...)
+}
+
+func gauge(name string, help string, value func(s snapshot) float64) metric {
+	return single(name, help, "gauge", value)
+}
+
+func counter(name string, help string, value func(s snapshot) float64) metric {
+	return single(name, help, "counter", value)
+}
+
+func single(name string, help string, kind string, value func(s snapshot) float64) metric {
+	return metric{
+		name: name,
+		help: help,
+		kind: kind,
+		write: func(w *textWriter, name string, s snapshot) {
+			w.sample(name, queueLabels(s), value(s))
+		},
+	}
+}
+
+//the buckets of the Prometheus histograms are cumulative, each of them counts the operations up to its bound
+func histogram(name string, help string, value func(s snapshot) ChanDB.Histogram) metric {
+	return metric{
+		name: name,
+		help: help,
+		kind: "histogram",
+		write: func(w *textWriter, name string, s snapshot) {
+			h := value(s)
+			cumulative := int64(0)
+
+			for i, bound := range h.Buckets {
+				cumulative += h.Counts[i]
+				w.sample(name+"_bucket", queueLabels(s, label{"le", formatValue(bound.Seconds())}), float64(cumulative))
+			}
+
+			w.sample(name+"_bucket", queueLabels(s, label{"le", "+Inf"}), float64(h.Count))
+			w.sample(name+"_sum", queueLabels(s), h.Sum.Seconds())
+			w.sample(name+"_count", queueLabels(s), float64(h.Count))
+		},
+	}
+}
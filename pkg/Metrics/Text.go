@@ -0,0 +1,60 @@
+package Metrics
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/**
+Writes the metrics in the Prometheus text exposition format, the errors of the writer are ignored, they are
+reported by the buffered writer that is flushed at the end
+*/
+type textWriter struct {
+	out io.Writer
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func createTextWriter(out io.Writer) *textWriter {
+	return &textWriter{out: out}
+}
+
+func (w *textWriter) header(name string, help string, kind string) {
+	io.WriteString(w.out, "# HELP "+name+" "+helpEscaper.Replace(help)+"\n")
+	io.WriteString(w.out, "# TYPE "+name+" "+kind+"\n")
+}
+
+func (w *textWriter) sample(name string, labels []label, value float64) {
+	line := name
+
+	if len(labels) > 0 {
+		pairs := make([]string, len(labels))
+		for i, l := range labels {
+			pairs[i] = l.name + `="` + labelEscaper.Replace(l.value) + `"`
+		}
+		line += "{" + strings.Join(pairs, ",") + "}"
+	}
+
+	io.WriteString(w.out, line+" "+formatValue(value)+"\n")
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
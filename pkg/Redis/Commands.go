@@ -0,0 +1,282 @@
+package Redis
+
+import (
+	"context"
+	"errors"
+	"github.com/theorx/ChanDB/internal/Network"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type command struct {
+	//number of arguments including the command name, a negative arity is the minimum number of arguments
+	arity   int
+	handler func(c *connection, args []string)
+}
+
+var commands = map[string]command{
+	"lpush":  {-3, push},
+	"rpush":  {-3, push},
+	"lpop":   {-2, pop},
+	"rpop":   {-2, pop},
+	"blpop":  {-3, blockingPop},
+	"brpop":  {-3, blockingPop},
+	"llen":   {2, length},
+	"del":    {-2, del},
+	"ping":   {-1, ping},
+	"echo":   {2, echo},
+	"select": {2, selectDB},
+	"quit":   {1, quit},
+}
+
+//operations of the queues used by the commands
+type queue interface {
+	WriteBatch(payloads []string) error
+	Read() (string, error)
+	ReadContext(ctx context.Context) (string, error)
+	Length() int64
+}
+
+//returns the queue of the key, nil when the queue does not exist and create is false
+func (c *connection) queue(key string, create bool) (queue, error) {
+	if create == false {
+		exists, err := c.server.registry.HasQueue(key)
+		if err != nil || exists == false {
+			return nil, err
+		}
+	}
+
+	q, err := c.server.registry.OpenQueue(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+func writeQueueError(c *connection, err error) {
+	writeError(c.writer, "ERR "+err.Error())
+}
+
+//LPUSH key record [record ...], replies with the length of the queue
+func push(c *connection, args []string) {
+	q, err := c.queue(args[1], true)
+	if err != nil {
+		writeQueueError(c, err)
+		return
+	}
+
+	err = q.WriteBatch(args[2:])
+	if err != nil {
+		writeQueueError(c, err)
+		return
+	}
+
+	writeInteger(c.writer, q.Length())
+}
+
+//LPOP key [count], replies with a record, or with an array of records when the count is given
+func pop(c *connection, args []string) {
+	if len(args) > 3 {
+		writeError(c.writer, "ERR syntax error")
+		return
+	}
+
+	count := 1
+	if len(args) == 3 {
+		var err error
+		count, err = strconv.Atoi(args[2])
+		if err != nil || count < 0 {
+			writeError(c.writer, "ERR value is out of range, must be positive")
+			return
+		}
+	}
+
+	q, err := c.queue(args[1], false)
+	if err != nil {
+		writeQueueError(c, err)
+		return
+	}
+
+	records := make([]string, 0)
+	for q != nil && len(records) < count {
+		record, err := q.Read()
+		if errors.Is(err, ChanDB.ErrEmpty) {
+			break
+		}
+
+		if err != nil {
+			writeQueueError(c, err)
+			return
+		}
+
+		records = append(records, record)
+	}
+
+	if len(args) == 3 {
+		if len(records) == 0 {
+			writeNullArray(c.writer)
+		} else {
+			writeArray(c.writer, records)
+		}
+		return
+	}
+
+	if len(records) == 0 {
+		writeNull(c.writer)
+		return
+	}
+
+	writeBulk(c.writer, records[0])
+}
+
+/**
+BLPOP key [key ...] timeout, pops from the first queue that has records and waits for a record when all of them
+are empty. The timeout is in seconds, 0 waits forever. The queues are created, so a record can be waited for
+before the first push
+*/
+func blockingPop(c *connection, args []string) {
+	seconds, err := strconv.ParseFloat(args[len(args)-1], 64)
+	if err != nil {
+		writeError(c.writer, "ERR timeout is not a float or out of range")
+		return
+	}
+
+	if seconds < 0 {
+		writeError(c.writer, "ERR timeout is negative")
+		return
+	}
+
+	keys := args[1 : len(args)-1]
+
+	//a client waiting for the reply sends nothing, the pop stops waiting when it disconnects
+	ctx, stop := Network.WatchDisconnect(c.ctx, time.Duration(seconds*float64(time.Second)), c.conn, c.reader)
+	key, record, err := c.popFirst(ctx, keys)
+	stop()
+
+	//the connection is being closed by Close()
+	if c.ctx.Err() != nil {
+		return
+	}
+
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		writeNullArray(c.writer)
+		return
+	}
+
+	if err != nil {
+		writeQueueError(c, err)
+		return
+	}
+
+	writeArray(c.writer, []string{key, record})
+}
+
+/**
+Waits for the first record of the queues. A single queue is waited for with ReadContext(), several queues are
+polled. A queue that is deleted while waiting is opened again
+*/
+func (c *connection) popFirst(ctx context.Context, keys []string) (string, string, error) {
+	for {
+		for _, key := range keys {
+			q, err := c.queue(key, true)
+			if err != nil {
+				return "", "", err
+			}
+
+			var record string
+			if len(keys) == 1 {
+				record, err = q.ReadContext(ctx)
+			} else {
+				record, err = q.Read()
+			}
+
+			if err == nil {
+				return key, record, nil
+			}
+
+			if ctx.Err() != nil {
+				return "", "", ctx.Err()
+			}
+
+			if errors.Is(err, ChanDB.ErrEmpty) == false && errors.Is(err, ChanDB.ErrClosed) == false {
+				return "", "", err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		case <-time.After(time.Millisecond * 25):
+		}
+	}
+}
+
+//LLEN key, 0 for the queues that do not exist
+func length(c *connection, args []string) {
+	q, err := c.queue(args[1], false)
+	if err != nil {
+		writeQueueError(c, err)
+		return
+	}
+
+	if q == nil {
+		writeInteger(c.writer, 0)
+		return
+	}
+
+	writeInteger(c.writer, q.Length())
+}
+
+//DEL key [key ...], deletes the queues and their files, replies with the number of queues deleted
+func del(c *connection, args []string) {
+	deleted := int64(0)
+
+	for _, key := range args[1:] {
+		err := c.server.registry.DropQueue(key)
+		if errors.Is(err, ChanDB.ErrQueueNotFound) {
+			continue
+		}
+
+		if err != nil {
+			writeQueueError(c, err)
+			return
+		}
+
+		deleted++
+	}
+
+	writeInteger(c.writer, deleted)
+}
+
+func ping(c *connection, args []string) {
+	switch len(args) {
+	case 1:
+		writeSimple(c.writer, "PONG")
+	case 2:
+		writeBulk(c.writer, args[1])
+	default:
+		writeError(c.writer, "ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func echo(c *connection, args []string) {
+	writeBulk(c.writer, args[1])
+}
+
+//there is only one database, the clients selecting database 0 are accepted
+func selectDB(c *connection, args []string) {
+	if strings.TrimSpace(args[1]) != "0" {
+		writeError(c.writer, "ERR DB index is out of range")
+		return
+	}
+
+	writeSimple(c.writer, "OK")
+}
+
+func quit(c *connection, args []string) {
+	writeSimple(c.writer, "OK")
+	c.quit = true
+}
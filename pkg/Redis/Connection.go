@@ -0,0 +1,77 @@
+package Redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+type connection struct {
+	server *Server
+	//done when the server is closed
+	ctx    context.Context
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	//set by QUIT, the connection is closed once the reply has been written
+	quit bool
+}
+
+func createConnection(server *Server, ctx context.Context, conn net.Conn) *connection {
+	return &connection{
+		server: server,
+		ctx:    ctx,
+		conn:   conn,
+		reader: bufio.NewReaderSize(conn, maxLineBytes),
+		writer: bufio.NewWriter(conn),
+	}
+}
+
+func (c *connection) serve() {
+	for c.quit == false {
+		args, err := readCommand(c.reader, c.server.settings.MaxBulkBytes)
+		if err != nil {
+			//the client is told what was wrong before the connection is closed, as Redis does
+			if errors.Is(err, errProtocol) {
+				writeError(c.writer, "ERR "+err.Error())
+				c.writer.Flush()
+			}
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		c.execute(args)
+
+		//the replies of pipelined commands are written together
+		if c.reader.Buffered() > 0 && c.quit == false {
+			continue
+		}
+
+		err = c.writer.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *connection) execute(args []string) {
+	name := strings.ToLower(args[0])
+
+	command, ok := commands[name]
+	if ok == false {
+		writeError(c.writer, "ERR unknown command '"+args[0]+"'")
+		return
+	}
+
+	if (command.arity > 0 && len(args) != command.arity) || len(args) < -command.arity {
+		writeError(c.writer, "ERR wrong number of arguments for '"+name+"' command")
+		return
+	}
+
+	command.handler(c, args)
+}
@@ -0,0 +1,122 @@
+package Redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	//longest line accepted, the bulk strings are not limited by it
+	maxLineBytes = 64 << 10
+	maxArguments = 1 << 20
+)
+
+var errProtocol = errors.New("Protocol error")
+
+/**
+Reads one command, an array of bulk strings as the clients send them or an inline command as typed into telnet.
+Returns an empty command for the empty inline lines
+*/
+func readCommand(reader *bufio.Reader, maxBulkBytes int64) ([]string, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > maxArguments {
+		return nil, protocolError("invalid multibulk length")
+	}
+
+	//the count is sent by the client, the arguments are allocated as they arrive
+	args := make([]string, 0)
+	for i := 0; i < count; i++ {
+		line, err = readLine(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 || line[0] != '$' {
+			return nil, protocolError("expected '$', got '" + line + "'")
+		}
+
+		size, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil || size < 0 || size > maxBulkBytes {
+			return nil, protocolError("invalid bulk length")
+		}
+
+		bulk := make([]byte, size+2)
+		_, err = io.ReadFull(reader, bulk)
+		if err != nil {
+			return nil, err
+		}
+
+		if bulk[size] != '\r' || bulk[size+1] != '\n' {
+			return nil, protocolError("bulk string is not terminated by CRLF")
+		}
+
+		args = append(args, string(bulk[:size]))
+	}
+
+	return args, nil
+}
+
+//reads a line terminated by "\r\n" or "\n", without the terminator
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", protocolError("too big inline request")
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+}
+
+func protocolError(message string) error {
+	return fmt.Errorf("%w: %s", errProtocol, message)
+}
+
+func writeSimple(writer *bufio.Writer, value string) {
+	writer.WriteString("+" + value + "\r\n")
+}
+
+//the message starts with the error code, such as "ERR unknown command"
+func writeError(writer *bufio.Writer, message string) {
+	writer.WriteString("-" + strings.Replace(message, "\n", " ", -1) + "\r\n")
+}
+
+func writeInteger(writer *bufio.Writer, value int64) {
+	writer.WriteString(":" + strconv.FormatInt(value, 10) + "\r\n")
+}
+
+func writeBulk(writer *bufio.Writer, value string) {
+	writer.WriteString("$" + strconv.Itoa(len(value)) + "\r\n")
+	writer.WriteString(value)
+	writer.WriteString("\r\n")
+}
+
+func writeNull(writer *bufio.Writer) {
+	writer.WriteString("$-1\r\n")
+}
+
+func writeNullArray(writer *bufio.Writer) {
+	writer.WriteString("*-1\r\n")
+}
+
+func writeArray(writer *bufio.Writer, values []string) {
+	writer.WriteString("*" + strconv.Itoa(len(values)) + "\r\n")
+	for _, value := range values {
+		writeBulk(writer, value)
+	}
+}
@@ -0,0 +1,76 @@
+package Redis
+
+import (
+	"context"
+	"github.com/theorx/ChanDB/internal/Network"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"net"
+)
+
+//returned by Serve() and ListenAndServe() once the server has been closed
+var ErrServerClosed = Network.ErrServerClosed
+
+type Settings struct {
+	/**
+	Largest argument accepted in a command, such as a pushed record. Default is 16MiB
+	*/
+	MaxBulkBytes int64
+}
+
+const defaultMaxBulkBytes = 16 << 20
+
+/**
+Server speaks the Redis protocol (RESP) and maps the list commands onto the queues of a registry, so Redis
+clients can use the queues as durable lists. Every key is a queue of the registry.
+
+The queues are FIFO, the commands of both ends of a list work on the same queue:
+
+	LPUSH, RPUSH    append the records to the end of the queue, in the order they are given
+	LPOP, RPOP      remove the oldest records
+	BLPOP, BRPOP    wait for the oldest record of the first queue holding one
+	LLEN, DEL       the length of a queue, deleting a queue and its files
+	PING, ECHO, SELECT 0, QUIT
+
+The queue patterns RPUSH+LPOP and LPUSH+RPOP work as in Redis. The stack patterns LPUSH+LPOP and RPUSH+RPOP
+return the oldest record instead of the newest one
+*/
+type Server struct {
+	registry *ChanDB.Registry
+	settings Settings
+	server   *Network.Server
+}
+
+func CreateServer(registry *ChanDB.Registry, settings Settings) *Server {
+	if settings.MaxBulkBytes <= 0 {
+		settings.MaxBulkBytes = defaultMaxBulkBytes
+	}
+
+	s := &Server{
+		registry: registry,
+		settings: settings,
+	}
+	s.server = Network.CreateServer(func(ctx context.Context, conn net.Conn) {
+		createConnection(s, ctx, conn).serve()
+	})
+
+	return s
+}
+
+func (s *Server) ListenAndServe(address string) error {
+	return s.server.ListenAndServe(address)
+}
+
+/**
+Serves the Redis clients connecting to the listener, returns ErrServerClosed after Close()
+*/
+func (s *Server) Serve(listener net.Listener) error {
+	return s.server.Serve(listener)
+}
+
+/**
+Closes the listeners and the client connections, the blocking pops waiting for records return without a reply.
+The registry is not closed
+*/
+func (s *Server) Close() error {
+	return s.server.Close()
+}
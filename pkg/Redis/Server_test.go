@@ -0,0 +1,273 @@
+package Redis
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+//starts a server on a registry in a temporary directory, the returned function closes both
+func startTestServer(t *testing.T) (*Server, string, func()) {
+	dir, err := ioutil.TempDir("", "chandb-redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := ChanDB.CreateRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := CreateServer(registry, Settings{})
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	return server, listener.Addr().String(), func() {
+		server.Close()
+		if err := <-served; err != ErrServerClosed {
+			t.Errorf("Serve returned %v", err)
+		}
+		registry.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func connect(t *testing.T, address string) *testClient {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testClient{t: t, conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+}
+
+//sends the command and returns the reply formatted as +simple, -error, :integer, $bulk, $nil, *[values] or *nil
+func (c *testClient) do(args ...string) string {
+	writeArray(c.writer, args)
+	err := c.writer.Flush()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return c.reply()
+}
+
+func (c *testClient) reply() string {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '$', '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			c.t.Fatalf("invalid reply %q", line)
+		}
+		if size < 0 {
+			return line[:1] + "nil"
+		}
+
+		if line[0] == '*' {
+			values := make([]string, size)
+			for i := range values {
+				values[i] = strings.TrimPrefix(c.reply(), "$")
+			}
+			return fmt.Sprintf("*%v", values)
+		}
+
+		bulk := make([]byte, size+2)
+		_, err = io.ReadFull(c.reader, bulk)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		return "$" + string(bulk[:size])
+	}
+
+	return line
+}
+
+func TestQueuePatterns(t *testing.T) {
+	_, address, stop := startTestServer(t)
+	defer stop()
+
+	client := connect(t, address)
+	defer client.conn.Close()
+
+	expect(t, client.do("RPUSH", "orders", "1", "2", "3"), ":3")
+	expect(t, client.do("LPOP", "orders"), "$1")
+	expect(t, client.do("LPOP", "orders", "5"), "*[2 3]")
+	expect(t, client.do("LPOP", "orders"), "$nil")
+
+	expect(t, client.do("LPUSH", "jobs", "a", "b"), ":2")
+	expect(t, client.do("LPUSH", "jobs", "c"), ":3")
+	expect(t, client.do("RPOP", "jobs"), "$a")
+	expect(t, client.do("BRPOP", "jobs", "1"), "*[jobs b]")
+	expect(t, client.do("LLEN", "jobs"), ":1")
+	expect(t, client.do("DEL", "jobs", "missing"), ":1")
+	expect(t, client.do("LLEN", "jobs"), ":0")
+
+	expect(t, client.do("PING"), "+PONG")
+	expect(t, client.do("SELECT", "1"), "-ERR DB index is out of range")
+}
+
+//the commands of both ends use the same queue, a list is not a stack
+func TestStackPatternsAreFIFO(t *testing.T) {
+	_, address, stop := startTestServer(t)
+	defer stop()
+
+	client := connect(t, address)
+	defer client.conn.Close()
+
+	expect(t, client.do("LPUSH", "stack", "1", "2", "3"), ":3")
+	expect(t, client.do("LPOP", "stack"), "$1")
+	expect(t, client.do("RPUSH", "stack", "4"), ":3")
+	expect(t, client.do("RPOP", "stack"), "$2")
+	expect(t, client.do("BLPOP", "stack", "1"), "*[stack 3]")
+	expect(t, client.do("BRPOP", "stack", "1"), "*[stack 4]")
+}
+
+func TestBlockingPop(t *testing.T) {
+	_, address, stop := startTestServer(t)
+	defer stop()
+
+	waiting := connect(t, address)
+	defer waiting.conn.Close()
+	pushing := connect(t, address)
+	defer pushing.conn.Close()
+
+	expect(t, waiting.do("BLPOP", "orders", "0.1"), "*nil")
+
+	received := make(chan string)
+	go func() {
+		received <- waiting.do("BLPOP", "empty", "orders", "5")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	expect(t, pushing.do("RPUSH", "orders", "first"), ":1")
+
+	select {
+	case reply := <-received:
+		expect(t, reply, "*[orders first]")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the blocking pop did not return")
+	}
+}
+
+//a client disconnecting while it waits does not take the record pushed after it left
+func TestBlockingPopClientGone(t *testing.T) {
+	_, address, stop := startTestServer(t)
+	defer stop()
+
+	gone := connect(t, address)
+	writeArray(gone.writer, []string{"BLPOP", "orders", "0"})
+	gone.writer.Flush()
+	time.Sleep(50 * time.Millisecond)
+	gone.conn.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	client := connect(t, address)
+	defer client.conn.Close()
+
+	expect(t, client.do("RPUSH", "orders", "kept"), ":1")
+	time.Sleep(50 * time.Millisecond)
+	expect(t, client.do("LPOP", "orders"), "$kept")
+}
+
+//the malformed commands are answered with an error and the connection is closed, the server keeps serving
+func TestMalformedCommands(t *testing.T) {
+	_, address, stop := startTestServer(t)
+	defer stop()
+
+	commands := []string{
+		"*-1\r\n",
+		"*-2147483648\r\n",
+		"*99999999999999999999\r\n",
+		"*two\r\n",
+		"*1\r\n$-1\r\n",
+		"*1\r\n$99999999999999999999\r\n",
+		"*1\r\n$four\r\n",
+		"*1\r\nPING\r\n",
+	}
+
+	for _, command := range commands {
+		client := connect(t, address)
+
+		_, err := client.conn.Write([]byte(command))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reply := client.reply()
+		if strings.HasPrefix(reply, "-ERR Protocol error") == false {
+			t.Fatalf("%q was answered with %q", command, reply)
+		}
+		client.conn.Close()
+
+		client = connect(t, address)
+		expect(t, client.do("PING"), "+PONG")
+		client.conn.Close()
+	}
+}
+
+func TestCloseStopsBlockingPop(t *testing.T) {
+	server, address, stop := startTestServer(t)
+	defer stop()
+
+	client := connect(t, address)
+	defer client.conn.Close()
+
+	writeArray(client.writer, []string{"BLPOP", "orders", "0"})
+	client.writer.Flush()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() {
+		closed <- server.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waits for the blocking pop")
+	}
+
+	_, err := client.reader.ReadByte()
+	if err == nil {
+		t.Fatal("the connection is still open")
+	}
+}
+
+func expect(t *testing.T, reply string, expected string) {
+	t.Helper()
+
+	if reply != expected {
+		t.Fatalf("replied %q, expected %q", reply, expected)
+	}
+}
@@ -0,0 +1,98 @@
+package Remote
+
+import (
+	"context"
+	"errors"
+	"github.com/theorx/ChanDB/internal/Network"
+	"github.com/theorx/ChanDB/internal/Protocol"
+	"time"
+)
+
+func (c *connection) serve() {
+	for {
+		op, body, err := Protocol.ReadFrame(c.reader)
+		if err != nil {
+			return
+		}
+
+		//the connection streams records until it is closed
+		if op == Protocol.OpSubscribe {
+			c.subscribe(body)
+			return
+		}
+
+		status, response := c.execute(op, body)
+
+		//the connection is being closed by Close()
+		if c.ctx.Err() != nil {
+			return
+		}
+
+		err = Protocol.WriteFrame(c.writer, status, response)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *connection) execute(op byte, body []byte) (byte, []byte) {
+	queue := c.server.queue
+
+	switch op {
+	case Protocol.OpPing:
+		return Protocol.StatusOK, nil
+	case Protocol.OpWrite:
+		payloads, err := Protocol.DecodeStrings(body)
+		if err != nil {
+			return Protocol.StatusError, []byte(err.Error())
+		}
+
+		return response(nil, queue.WriteBatch(payloads))
+	case Protocol.OpRead:
+		wait, err := Protocol.DecodeInt(body)
+		if err != nil {
+			return Protocol.StatusError, []byte(err.Error())
+		}
+
+		if wait <= 0 {
+			record, err := queue.Read()
+			return response([]byte(record), err)
+		}
+
+		return c.readWait(time.Duration(wait))
+	case Protocol.OpLength:
+		return Protocol.StatusOK, Protocol.EncodeInt(queue.Length())
+	case Protocol.OpTruncate:
+		return response(nil, queue.Truncate())
+	}
+
+	return Protocol.StatusError, []byte("unknown operation")
+}
+
+/**
+Waits for a record at most for MaxWait. The client sends nothing while it waits for the response, the waiting stops
+when it disconnects so a record is not taken for a client that is gone
+*/
+func (c *connection) readWait(wait time.Duration) (byte, []byte) {
+	if wait > c.server.settings.MaxWait {
+		wait = c.server.settings.MaxWait
+	}
+
+	ctx, stop := Network.WatchDisconnect(c.ctx, wait, c.conn, c.reader)
+	record, err := c.server.queue.ReadContext(ctx)
+	stop()
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return Protocol.StatusEmpty, nil
+	}
+
+	return response([]byte(record), err)
+}
+
+func response(body []byte, err error) (byte, []byte) {
+	if err != nil {
+		return Protocol.ErrorResponse(err)
+	}
+
+	return Protocol.StatusOK, body
+}
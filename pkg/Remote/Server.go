@@ -0,0 +1,97 @@
+package Remote
+
+import (
+	"bufio"
+	"context"
+	"github.com/theorx/ChanDB/internal/Network"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"net"
+	"time"
+)
+
+//returned by Serve() and ListenAndServe() once the server has been closed
+var ErrServerClosed = Network.ErrServerClosed
+
+type Settings struct {
+	/**
+	Longest time a read waits for a record, the clients wait longer by sending the read again. Default is 30 seconds
+	*/
+	MaxWait time.Duration
+}
+
+const defaultMaxWait = 30 * time.Second
+
+/**
+Operations of the database used by the server, the databases created by ChanDB implement it
+*/
+type Queue interface {
+	WriteBatch(payloads []string) error
+	Read() (string, error)
+	ReadContext(ctx context.Context) (string, error)
+	Length() int64
+	Truncate() error
+	ReadAckStream() ChanDB.AckStream
+}
+
+/**
+Server gives the clients of the github.com/theorx/ChanDB/pkg/Client package access to one database over TCP
+*/
+type Server struct {
+	queue    Queue
+	settings Settings
+	server   *Network.Server
+}
+
+func CreateServer(queue Queue, settings Settings) *Server {
+	if settings.MaxWait <= 0 {
+		settings.MaxWait = defaultMaxWait
+	}
+
+	s := &Server{
+		queue:    queue,
+		settings: settings,
+	}
+	s.server = Network.CreateServer(func(ctx context.Context, conn net.Conn) {
+		createConnection(s, ctx, conn).serve()
+	})
+
+	return s
+}
+
+func (s *Server) ListenAndServe(address string) error {
+	return s.server.ListenAndServe(address)
+}
+
+/**
+Serves the clients connecting to the listener, returns ErrServerClosed after Close()
+*/
+func (s *Server) Serve(listener net.Listener) error {
+	return s.server.Serve(listener)
+}
+
+/**
+Closes the listeners and the client connections, the reads waiting for records return without taking one and the
+subscriptions restore their unacknowledged records. The database is not closed
+*/
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+type connection struct {
+	server *Server
+	//done when the server is closed
+	ctx    context.Context
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func createConnection(server *Server, ctx context.Context, conn net.Conn) *connection {
+	return &connection{
+		server: server,
+		ctx:    ctx,
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}
+}
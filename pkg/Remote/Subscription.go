@@ -0,0 +1,211 @@
+package Remote
+
+import (
+	"context"
+	"github.com/theorx/ChanDB/internal/Protocol"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"sync"
+)
+
+//credits above the limit are ignored, so a client can not make the counter overflow
+const maxCredits = 1 << 20
+
+/**
+Streaming connection started by OpSubscribe. The records are taken from a ChanDB.AckStream of the database, one for
+each credit granted by the client, so the records stay in the database while the client is not ready for them.
+The records stay held until the client acknowledges them, the ones that have not been acknowledged when the
+connection ends are restored to their places at the head of the database. They are lost when the server crashes,
+see ChanDB.AckStream
+*/
+type subscription struct {
+	conn      *connection
+	ctx       context.Context
+	cancel    context.CancelFunc
+	lock      *sync.Mutex
+	writeLock *sync.Mutex
+	credits   int64
+	granted   chan bool
+	stream    ChanDB.AckStream
+	nextID    uint64
+	inflight  map[uint64]ChanDB.Delivery
+}
+
+func (c *connection) subscribe(body []byte) {
+	credits, err := Protocol.DecodeInt(body)
+	if err != nil || credits < 0 {
+		Protocol.WriteFrame(c.writer, Protocol.StatusError, []byte("invalid credits"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+
+	s := &subscription{
+		conn:      c,
+		ctx:       ctx,
+		cancel:    cancel,
+		lock:      &sync.Mutex{},
+		writeLock: &sync.Mutex{},
+		granted:   make(chan bool, 1),
+		stream:    c.server.queue.ReadAckStream(),
+		inflight:  make(map[uint64]ChanDB.Delivery),
+	}
+	s.grant(credits)
+
+	//the records that have not been acknowledged are restored to their places in the database
+	defer s.stream.Close()
+
+	err = s.write(Protocol.StatusOK, nil)
+	if err != nil {
+		return
+	}
+
+	receiving := make(chan bool)
+	go func() {
+		defer close(receiving)
+		s.receive()
+	}()
+
+	s.send()
+
+	//the acknowledgements arriving after the receiving stops are lost, those records are read again
+	c.conn.Close()
+	<-receiving
+}
+
+//sends the records of the stream while the client has credits
+func (s *subscription) send() {
+	defer s.cancel()
+
+	for {
+		if s.takeCredit() == false {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-s.granted:
+				continue
+			}
+		}
+
+		//the record is taken from the database only once the client has a credit for it, fails once the database
+		//has been closed
+		delivery, err := s.stream.Receive(s.ctx)
+		if err != nil {
+			return
+		}
+
+		s.lock.Lock()
+		s.nextID++
+		id := s.nextID
+		s.inflight[id] = delivery
+		s.lock.Unlock()
+
+		err = s.write(Protocol.StatusRecord, Protocol.EncodeWithID(id, []byte(delivery.Payload)))
+		if err != nil {
+			return
+		}
+	}
+}
+
+//reads the frames of the client until the connection fails
+func (s *subscription) receive() {
+	defer s.cancel()
+
+	for {
+		op, body, err := Protocol.ReadFrame(s.conn.reader)
+		if err != nil {
+			return
+		}
+
+		switch op {
+		case Protocol.OpCredit:
+			credits, err := Protocol.DecodeInt(body)
+			if err != nil || credits < 0 {
+				return
+			}
+			s.grant(credits)
+		case Protocol.OpAck:
+			ids, err := Protocol.DecodeIDs(body)
+			if err != nil {
+				return
+			}
+			s.acknowledge(ids)
+		case Protocol.OpProduce:
+			err = s.produce(body)
+			if err != nil {
+				return
+			}
+		default:
+			s.write(Protocol.StatusError, []byte("unknown operation"))
+			return
+		}
+	}
+}
+
+//the records are written before the next frame is read, so the responses are sent in the order of the requests
+func (s *subscription) produce(body []byte) error {
+	id, records, err := Protocol.DecodeWithID(body)
+	if err != nil {
+		return err
+	}
+
+	payloads, err := Protocol.DecodeStrings(records)
+	if err != nil {
+		return err
+	}
+
+	status, message := response(nil, s.conn.server.queue.WriteBatch(payloads))
+
+	return s.write(status, Protocol.EncodeWithID(id, message))
+}
+
+func (s *subscription) grant(credits int64) {
+	s.lock.Lock()
+	s.credits += credits
+	if s.credits > maxCredits {
+		s.credits = maxCredits
+	}
+	s.lock.Unlock()
+
+	select {
+	case s.granted <- true:
+	default:
+	}
+}
+
+func (s *subscription) takeCredit() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.credits == 0 {
+		return false
+	}
+
+	s.credits--
+	return true
+}
+
+//the records are removed from the database once they are acknowledged
+func (s *subscription) acknowledge(ids []uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, id := range ids {
+		delivery, ok := s.inflight[id]
+		if ok == false {
+			continue
+		}
+
+		delete(s.inflight, id)
+		//fails once the database is closing, the record has been restored then and it is read again
+		s.stream.Ack(delivery)
+	}
+}
+
+//the records and the responses to the produced records are written by different goroutines
+func (s *subscription) write(status byte, body []byte) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	return Protocol.WriteFrame(s.conn.writer, status, body)
+}
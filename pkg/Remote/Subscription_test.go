@@ -0,0 +1,208 @@
+package Remote
+
+import (
+	"context"
+	"errors"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"github.com/theorx/ChanDB/pkg/Client"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//serves the database on a loopback listener, the returned function closes the server
+func serveTestDatabase(t *testing.T, db Queue) (*Client.Client, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := CreateServer(db, Settings{})
+	go server.Serve(listener)
+
+	client, err := Client.CreateClient(listener.Addr().String(), Client.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, func() {
+		client.Close()
+		server.Close()
+	}
+}
+
+func receiveMessages(t *testing.T, subscription *Client.Subscription, count int) []Client.Message {
+	messages := make([]Client.Message, 0)
+
+	for len(messages) < count {
+		select {
+		case message, ok := <-subscription.Messages():
+			if ok == false {
+				t.Fatal(subscription.Err())
+			}
+			messages = append(messages, message)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no record received")
+		}
+	}
+
+	return messages
+}
+
+func readAll(t *testing.T, db ChanDB.Database) []string {
+	records := make([]string, 0)
+
+	for {
+		record, err := db.Read()
+		if errors.Is(err, ChanDB.ErrEmpty) {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		records = append(records, record)
+	}
+}
+
+//waits until the server has restored the records of the closed subscription
+func waitForLength(t *testing.T, db ChanDB.Database, length int64) {
+	for i := 0; db.Length() != length; i++ {
+		if i == 500 {
+			t.Fatalf("the database holds %d records, expected %d", db.Length(), length)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUnacknowledgedRecordsRestoredInOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chandb-remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ChanDB.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.WriteBatch([]string{"r0", "r1", "r2", "r3", "r4", "r5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, stop := serveTestDatabase(t, db)
+	defer stop()
+
+	subscription, err := client.Subscribe(context.Background(), Client.SubscriptionSettings{Window: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := receiveMessages(t, subscription, 3)
+	err = subscription.Ack(messages[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the records written meanwhile stay after the restored ones
+	err = db.Write("r6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscription.Close()
+	waitForLength(t, db, 6)
+
+	records := readAll(t, db)
+	if reflect.DeepEqual(records, []string{"r0", "r2", "r3", "r4", "r5", "r6"}) == false {
+		t.Fatalf("records are %v", records)
+	}
+}
+
+//the database closing before the server does not lose the records the subscriptions hold
+func TestUnacknowledgedRecordsKeptWhenDatabaseCloses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chandb-remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ChanDB.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.WriteBatch([]string{"r0", "r1", "r2", "r3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, stop := serveTestDatabase(t, db)
+	defer stop()
+
+	subscription, err := client.Subscribe(context.Background(), Client.SubscriptionSettings{Window: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Close()
+
+	receiveMessages(t, subscription, 2)
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = ChanDB.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	records := readAll(t, db)
+	if reflect.DeepEqual(records, []string{"r0", "r1", "r2", "r3"}) == false {
+		t.Fatalf("records are %v", records)
+	}
+}
+
+//the server takes a record from the database only when the client has a credit for it
+func TestSubscriptionReadsOnlyWithCredits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chandb-remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ChanDB.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.WriteBatch([]string{"r0", "r1", "r2", "r3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, stop := serveTestDatabase(t, db)
+	defer stop()
+
+	subscription, err := client.Subscribe(context.Background(), Client.SubscriptionSettings{Window: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Close()
+
+	receiveMessages(t, subscription, 2)
+	time.Sleep(50 * time.Millisecond)
+
+	if reads := db.Stats().Reads; reads != 2 {
+		t.Fatalf("the server took %d records for a window of 2", reads)
+	}
+}
@@ -0,0 +1,290 @@
+package Replication
+
+import (
+	"context"
+	"github.com/theorx/ChanDB/internal/Network"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"github.com/theorx/ChanDB/pkg/Signal"
+	"net"
+	"sync"
+	"time"
+)
+
+//returned by Serve() and ListenAndServe() of the primary once it has been closed
+var ErrServerClosed = Network.ErrServerClosed
+
+type PrimarySettings struct {
+	/**
+	Number of changes kept for the replicas that are behind, default is 100000. A replica missing older changes
+	is sent all of the records again
+	*/
+	LogSize int
+	/**
+	How often the replicas are told the sequence of the primary while there are no changes, default is 1 second
+	*/
+	HeartbeatInterval time.Duration
+	/**
+	Directory of the temporary files holding the records sent to new replicas, default is the system directory
+	*/
+	TempDir string
+	/**
+	Told why a replica disconnected, unless the primary was closed
+	*/
+	Logger ChanDB.Logger
+}
+
+const (
+	defaultLogSize           = 100000
+	defaultHeartbeatInterval = time.Second
+	handshakeTimeout         = 10 * time.Second
+)
+
+/**
+Operations of the database replicated by the primary, the databases created by ChanDB implement it
+*/
+type Source interface {
+	SetChangeListener(listener ChanDB.ChangeListener) error
+	SnapshotRecords(visit func(payload string) error) (uint64, error)
+}
+
+/**
+Primary sends the changes of a database to the replicas connecting to it. The recent changes are kept in memory,
+a replica that reconnects continues from the change it has applied last, a new replica or a replica that is
+further behind than the kept changes is sent all of the records first. The replicas apply the changes only at
+the ends of their databases, a record held by a stream that is closed without delivering it is written to the end
+of the replicated database instead of being restored to its place
+*/
+type Primary struct {
+	source   Source
+	settings PrimarySettings
+	//identifies the sequences of this primary, the replicas of another primary are sent all of the records
+	epoch uint64
+	lock  *sync.Mutex
+	//the changes kept for the replicas, the first one has the sequence first
+	changes  []ChanDB.Change
+	first    uint64
+	last     uint64
+	changed  *Signal.Broadcast
+	replicas map[*replicaConnection]bool
+	server   *Network.Server
+}
+
+/**
+Starts listening to the changes of the database, the changes are kept until they are sent with Serve()
+*/
+func CreatePrimary(source Source, settings PrimarySettings) (*Primary, error) {
+	if settings.LogSize <= 0 {
+		settings.LogSize = defaultLogSize
+	}
+
+	if settings.HeartbeatInterval <= 0 {
+		settings.HeartbeatInterval = defaultHeartbeatInterval
+	}
+
+	p := &Primary{
+		source:   source,
+		settings: settings,
+		epoch:    uint64(time.Now().UnixNano()),
+		lock:     &sync.Mutex{},
+		changes:  make([]ChanDB.Change, 0),
+		first:    1,
+		changed:  Signal.CreateBroadcast(),
+		replicas: make(map[*replicaConnection]bool),
+	}
+	p.server = Network.CreateServer(p.serveReplica)
+
+	err := source.SetChangeListener(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+/**
+Called by the database while its locks are held, the change is only stored and the replica connections are woken up
+*/
+func (p *Primary) OnChange(change ChanDB.Change) {
+	//the records belong to the caller of the write
+	change.Records = append([]string(nil), change.Records...)
+
+	p.lock.Lock()
+	if len(p.changes) == 0 {
+		p.first = change.Sequence
+	}
+	p.changes = append(p.changes, change)
+	for len(p.changes) > p.settings.LogSize {
+		p.changes[0] = ChanDB.Change{}
+		p.changes = p.changes[1:]
+		p.first++
+	}
+	p.last = change.Sequence
+	p.lock.Unlock()
+
+	p.changed.Notify()
+}
+
+func (p *Primary) ListenAndServe(address string) error {
+	return p.server.ListenAndServe(address)
+}
+
+/**
+Accepts the replica connections until the primary is closed, returns ErrServerClosed after Close()
+*/
+func (p *Primary) Serve(listener net.Listener) error {
+	return p.server.Serve(listener)
+}
+
+/**
+Stops listening to the changes of the database and closes the replica connections, the database is not closed
+*/
+func (p *Primary) Close() error {
+	err := p.server.Close()
+
+	//fails when the database has been closed already, the changes are not reported then anyway
+	p.source.SetChangeListener(nil)
+
+	return err
+}
+
+type PrimaryStats struct {
+	Epoch uint64
+	//sequence of the last change
+	Sequence uint64
+	//number of changes kept for the replicas
+	LogChanges int
+	Replicas   []ReplicaLag
+}
+
+type ReplicaLag struct {
+	Address string
+	//the replica is receiving all of the records
+	Syncing bool
+	//sequence of the last change the replica has applied
+	Applied uint64
+	//number of changes the replica has not applied
+	Lag uint64
+	//time since the first change the replica has not applied was made, 0 when the change is not kept anymore
+	LagTime time.Duration
+}
+
+func (p *Primary) Stats() PrimaryStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := PrimaryStats{
+		Epoch:      p.epoch,
+		Sequence:   p.last,
+		LogChanges: len(p.changes),
+		Replicas:   make([]ReplicaLag, 0, len(p.replicas)),
+	}
+
+	for replica := range p.replicas {
+		lag := ReplicaLag{
+			Address: replica.conn.RemoteAddr().String(),
+			Syncing: replica.syncing,
+			Applied: replica.applied,
+		}
+
+		if replica.applied < p.last {
+			lag.Lag = p.last - replica.applied
+			if replica.applied+1 >= p.first {
+				lag.LagTime = time.Since(p.changes[replica.applied+1-p.first].Time)
+			}
+		}
+
+		stats.Replicas = append(stats.Replicas, lag)
+	}
+
+	return stats
+}
+
+/**
+Returns the changes following the sequence, at most batchBytes of records. The channel is closed when there are
+new changes, it is returned when there are none. Fails when the changes following the sequence are not kept anymore
+*/
+func (p *Primary) changesAfter(sequence uint64) ([]ChanDB.Change, <-chan bool, error) {
+	//taken before checking for the changes, so a change made after the check is not missed
+	changed := p.changed.Wait()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if sequence >= p.last {
+		return nil, changed, nil
+	}
+
+	if sequence+1 < p.first {
+		return nil, nil, errReplicaBehind
+	}
+
+	changes := make([]ChanDB.Change, 0)
+	size := 0
+	for _, change := range p.changes[sequence+1-p.first:] {
+		for _, record := range change.Records {
+			size += len(record)
+		}
+
+		changes = append(changes, change)
+		if size >= batchBytes {
+			break
+		}
+	}
+
+	return changes, nil, nil
+}
+
+//tells whether a replica of this primary can continue from the sequence without receiving all of the records
+func (p *Primary) canResume(epoch uint64, sequence uint64) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return epoch == p.epoch && sequence+1 >= p.first && sequence <= p.last
+}
+
+func (p *Primary) sequence() uint64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.last
+}
+
+func (p *Primary) setApplied(replica *replicaConnection, sequence uint64, syncing bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	replica.applied = sequence
+	replica.syncing = syncing
+}
+
+func (p *Primary) addReplica(replica *replicaConnection) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.replicas[replica] = true
+}
+
+func (p *Primary) removeReplica(replica *replicaConnection) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.replicas, replica)
+}
+
+func (p *Primary) log(level ChanDB.Level, msg string, keyvals ...interface{}) {
+	if p.settings.Logger != nil {
+		p.settings.Logger.Log(level, msg, keyvals...)
+	}
+}
+
+func (p *Primary) serveReplica(ctx context.Context, conn net.Conn) {
+	replica := createReplicaConnection(p, conn)
+
+	p.addReplica(replica)
+	defer p.removeReplica(replica)
+
+	err := replica.serve(ctx)
+	if err != nil && ctx.Err() == nil {
+		p.log(ChanDB.LevelWarn, "replica disconnected", "replica", conn.RemoteAddr().String(), "error", err)
+	}
+}
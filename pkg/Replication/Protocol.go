@@ -0,0 +1,120 @@
+package Replication
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/theorx/ChanDB/internal/Protocol"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"time"
+)
+
+/**
+The replication connections use the frames of the remote protocol. The replica starts with opReplicate and the
+primary answers with statusResume when it still has the changes the replica is missing, otherwise it sends all of
+the records with statusSnapshot, statusRecords and statusSnapshotEnd. After that the primary sends the changes with
+statusChanges and statusHeartbeat frames, the replica answers them with opApplied frames
+*/
+const (
+	//the body is the epoch and the sequence of the last change the replica has applied, both 0 for a new replica
+	opReplicate byte = iota + 1
+	//the body is the sequence of the last change the replica has applied
+	opApplied
+)
+
+const (
+	//the body is the epoch and the sequence of the change the replica continues from
+	statusResume byte = iota + 1
+	//the replica removes its records, the body is the epoch and the sequence the records include
+	statusSnapshot
+	//the body is a list of records of the snapshot
+	statusRecords
+	statusSnapshotEnd
+	//the body is a list of changes, each of them follows the previous one
+	statusChanges
+	//the body is the sequence of the last change of the primary, sent while there are no changes
+	statusHeartbeat
+	//the body is the error message, the primary closes the connection after it
+	statusError
+)
+
+//the frames sent to the replicas are split at this size
+const batchBytes = 1 << 20
+
+var errTruncatedFrame = errors.New("truncated replication frame")
+
+func encodePosition(epoch uint64, sequence uint64) []byte {
+	body := make([]byte, 16)
+	binary.BigEndian.PutUint64(body, epoch)
+	binary.BigEndian.PutUint64(body[8:], sequence)
+
+	return body
+}
+
+func decodePosition(body []byte) (uint64, uint64, error) {
+	if len(body) != 16 {
+		return 0, 0, errTruncatedFrame
+	}
+
+	return binary.BigEndian.Uint64(body), binary.BigEndian.Uint64(body[8:]), nil
+}
+
+func encodeSequence(sequence uint64) []byte {
+	return Protocol.EncodeInt(int64(sequence))
+}
+
+func decodeSequence(body []byte) (uint64, error) {
+	sequence, err := Protocol.DecodeInt(body)
+	return uint64(sequence), err
+}
+
+/**
+Each change is stored as its sequence, time in unix nanoseconds, kind, count and the length of the records
+followed by the records
+*/
+func appendChange(body []byte, change ChanDB.Change) []byte {
+	records := Protocol.EncodeStrings(change.Records)
+
+	header := make([]byte, 29)
+	binary.BigEndian.PutUint64(header, change.Sequence)
+	binary.BigEndian.PutUint64(header[8:], uint64(change.Time.UnixNano()))
+	header[16] = byte(change.Kind)
+	binary.BigEndian.PutUint64(header[17:], uint64(change.Count))
+	binary.BigEndian.PutUint32(header[25:], uint32(len(records)))
+
+	body = append(body, header...)
+	return append(body, records...)
+}
+
+func decodeChanges(body []byte) ([]ChanDB.Change, error) {
+	changes := make([]ChanDB.Change, 0)
+
+	for len(body) > 0 {
+		if len(body) < 29 {
+			return nil, errTruncatedFrame
+		}
+
+		change := ChanDB.Change{
+			Sequence: binary.BigEndian.Uint64(body),
+			Time:     time.Unix(0, int64(binary.BigEndian.Uint64(body[8:]))),
+			Kind:     ChanDB.ChangeKind(body[16]),
+			Count:    int64(binary.BigEndian.Uint64(body[17:])),
+		}
+
+		size := binary.BigEndian.Uint32(body[25:])
+		body = body[29:]
+		if uint32(len(body)) < size {
+			return nil, errTruncatedFrame
+		}
+
+		records, err := Protocol.DecodeStrings(body[:size])
+		if err != nil {
+			return nil, err
+		}
+		change.Records = records
+		body = body[size:]
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,419 @@
+package Replication
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/theorx/ChanDB/internal/Protocol"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrPromoted = errors.New("replica has been promoted")
+
+type ReplicaSettings struct {
+	/**
+	Wait between the attempts to connect to the primary, default is 1 second
+	*/
+	ReconnectInterval time.Duration
+	/**
+	Default is 5 seconds
+	*/
+	DialTimeout time.Duration
+	/**
+	Longest wait for a frame from the primary before the connection is considered lost, default is 10 seconds. The
+	primary sends a heartbeat every HeartbeatInterval while there are no changes, the timeout has to be a few
+	heartbeat intervals. There is no timeout for the first frame, it is sent once the primary has copied its
+	records for a new replica
+	*/
+	Timeout time.Duration
+	/**
+	Told why the replication stopped each time the replica reconnects to the primary
+	*/
+	Logger ChanDB.Logger
+}
+
+const (
+	defaultReconnectInterval = time.Second
+	defaultDialTimeout       = 5 * time.Second
+	defaultTimeout           = 10 * time.Second
+)
+
+//the records of the replica differ from the records of the primary, the replica is sent all of the records again
+var errDiverged = errors.New("replica holds fewer records than the primary consumed")
+
+/**
+Operations of the database the changes are applied to, the databases created by ChanDB implement it
+*/
+type Target interface {
+	WriteBatch(payloads []string) error
+	Read() (string, error)
+	Truncate() error
+}
+
+/**
+Replica applies the changes of a primary to a database of its own. Nothing else may change the database while
+it is being replicated, once the replica has been promoted the database can be used and replicated as any other
+*/
+type Replica struct {
+	target   Target
+	address  string
+	settings ReplicaSettings
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan bool
+	lock     *sync.Mutex
+	//the primary and the sequence of the last change applied, the epoch is 0 until the records have been received
+	epoch    uint64
+	applied  uint64
+	primary  uint64
+	delay    time.Duration
+	contact  time.Time
+	syncing  bool
+	conn     net.Conn
+	err      error
+	promoted bool
+	//the position of the records being received
+	syncEpoch    uint64
+	syncSequence uint64
+}
+
+/**
+Starts replicating the primary at the address in the background. The records of the database are replaced by
+the records of the primary when the replica connects for the first time
+*/
+func CreateReplica(target Target, address string, settings ReplicaSettings) *Replica {
+	if settings.ReconnectInterval <= 0 {
+		settings.ReconnectInterval = defaultReconnectInterval
+	}
+
+	if settings.DialTimeout <= 0 {
+		settings.DialTimeout = defaultDialTimeout
+	}
+
+	if settings.Timeout <= 0 {
+		settings.Timeout = defaultTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := &Replica{
+		target:   target,
+		address:  address,
+		settings: settings,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan bool),
+		lock:     &sync.Mutex{},
+	}
+
+	go r.run()
+
+	return r
+}
+
+type ReplicaStats struct {
+	Connected bool
+	//the records of the primary are being received, the database holds only some of them
+	Syncing  bool
+	Promoted bool
+	//sequence of the last change applied
+	Applied uint64
+	//sequence of the last change of the primary known to the replica
+	PrimarySequence uint64
+	//number of changes the replica has not applied
+	Lag uint64
+	/**
+	Time from the primary making the last applied change to the replica applying it, measured with the clocks of
+	both hosts
+	*/
+	Delay time.Duration
+	//when the replica last received a frame from the primary
+	LastContact time.Time
+	//the error that ended the last connection
+	Err error
+}
+
+func (r *Replica) Stats() ReplicaStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	stats := ReplicaStats{
+		Connected:       r.conn != nil,
+		Syncing:         r.syncing,
+		Promoted:        r.promoted,
+		Applied:         r.applied,
+		PrimarySequence: r.primary,
+		Delay:           r.delay,
+		LastContact:     r.contact,
+		Err:             r.err,
+	}
+
+	if r.primary > r.applied {
+		stats.Lag = r.primary - r.applied
+	}
+
+	return stats
+}
+
+/**
+Stops replicating, the changes that have not been received are lost. The database can be written and read after
+it, a Primary can be created for it for the other replicas, which receive all of its records. A replica that is
+still Syncing holds only some of the records of the primary
+*/
+func (r *Replica) Promote() error {
+	r.lock.Lock()
+	if r.promoted {
+		r.lock.Unlock()
+		return ErrPromoted
+	}
+	r.promoted = true
+	r.lock.Unlock()
+
+	r.stop()
+
+	return nil
+}
+
+/**
+Stops replicating without promoting the replica
+*/
+func (r *Replica) Close() error {
+	r.stop()
+	return nil
+}
+
+func (r *Replica) stop() {
+	r.cancel()
+
+	r.lock.Lock()
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	r.lock.Unlock()
+
+	<-r.done
+}
+
+func (r *Replica) run() {
+	defer close(r.done)
+
+	for {
+		err := r.replicate()
+
+		r.lock.Lock()
+		r.conn = nil
+		if r.ctx.Err() == nil {
+			r.err = err
+		}
+		r.lock.Unlock()
+
+		if r.ctx.Err() != nil {
+			return
+		}
+
+		r.log(ChanDB.LevelWarn, "replication stopped, reconnecting", "primary", r.address, "error", err)
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(r.settings.ReconnectInterval):
+		}
+	}
+}
+
+//connects to the primary and applies its changes until the connection fails
+func (r *Replica) replicate() error {
+	dialer := net.Dialer{Timeout: r.settings.DialTimeout}
+	conn, err := dialer.DialContext(r.ctx, "tcp", r.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	r.lock.Lock()
+	if r.ctx.Err() != nil {
+		r.lock.Unlock()
+		return r.ctx.Err()
+	}
+	r.conn = conn
+	epoch, sequence := r.epoch, r.applied
+	r.lock.Unlock()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	err = Protocol.WriteFrame(writer, opReplicate, encodePosition(epoch, sequence))
+	if err != nil {
+		return err
+	}
+
+	for received := false; ; received = true {
+		//a connection that stays silent for longer than the heartbeats of the primary is lost
+		if received {
+			conn.SetReadDeadline(time.Now().Add(r.settings.Timeout))
+		}
+
+		status, body, err := Protocol.ReadFrame(reader)
+		if err != nil {
+			return err
+		}
+
+		r.lock.Lock()
+		r.contact = time.Now()
+		r.lock.Unlock()
+
+		err = r.handle(status, body)
+		if err != nil {
+			return err
+		}
+
+		//the primary is told about the applied changes, it is not waiting for the records of a snapshot
+		if status == statusChanges || status == statusHeartbeat || status == statusSnapshotEnd {
+			err = Protocol.WriteFrame(writer, opApplied, encodeSequence(r.appliedSequence()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *Replica) handle(status byte, body []byte) error {
+	switch status {
+	case statusResume:
+		epoch, sequence, err := decodePosition(body)
+		if err != nil {
+			return err
+		}
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+
+		if epoch != r.epoch || sequence != r.applied {
+			return fmt.Errorf("the primary resumed from sequence %d of epoch %d", sequence, epoch)
+		}
+		return nil
+	case statusSnapshot:
+		epoch, sequence, err := decodePosition(body)
+		if err != nil {
+			return err
+		}
+
+		//the replica receives all of the records again when the connection fails before they have been received
+		r.lock.Lock()
+		r.epoch = 0
+		r.applied = 0
+		r.syncing = true
+		r.syncEpoch = epoch
+		r.syncSequence = sequence
+		r.primary = sequence
+		r.lock.Unlock()
+
+		return r.target.Truncate()
+	case statusRecords:
+		records, err := Protocol.DecodeStrings(body)
+		if err != nil {
+			return err
+		}
+
+		return r.target.WriteBatch(records)
+	case statusSnapshotEnd:
+		r.lock.Lock()
+		r.epoch = r.syncEpoch
+		r.applied = r.syncSequence
+		r.syncing = false
+		if r.primary < r.applied {
+			r.primary = r.applied
+		}
+		r.lock.Unlock()
+		return nil
+	case statusChanges:
+		changes, err := decodeChanges(body)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			err = r.apply(change)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case statusHeartbeat:
+		sequence, err := decodeSequence(body)
+		if err != nil {
+			return err
+		}
+
+		r.lock.Lock()
+		r.primary = sequence
+		r.lock.Unlock()
+		return nil
+	case statusError:
+		return fmt.Errorf("primary failed: %s", body)
+	}
+
+	return fmt.Errorf("unknown status %d", status)
+}
+
+func (r *Replica) apply(change ChanDB.Change) error {
+	applied := r.appliedSequence()
+	if change.Sequence != applied+1 {
+		return fmt.Errorf("expected change %d, received change %d", applied+1, change.Sequence)
+	}
+
+	var err error
+	switch change.Kind {
+	case ChanDB.ChangeWrite:
+		err = r.target.WriteBatch(change.Records)
+	case ChanDB.ChangeConsume:
+		for i := int64(0); i < change.Count; i++ {
+			_, err = r.target.Read()
+			if errors.Is(err, ChanDB.ErrEmpty) {
+				r.lock.Lock()
+				r.epoch = 0
+				r.applied = 0
+				r.lock.Unlock()
+				err = errDiverged
+			}
+			if err != nil {
+				break
+			}
+		}
+	case ChanDB.ChangeTruncate:
+		err = r.target.Truncate()
+	default:
+		err = fmt.Errorf("unknown kind %d", change.Kind)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed applying change %d: %w", change.Sequence, err)
+	}
+
+	r.lock.Lock()
+	r.applied = change.Sequence
+	if r.primary < r.applied {
+		r.primary = r.applied
+	}
+	r.delay = time.Since(change.Time)
+	r.lock.Unlock()
+
+	return nil
+}
+
+func (r *Replica) appliedSequence() uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.applied
+}
+
+func (r *Replica) log(level ChanDB.Level, msg string, keyvals ...interface{}) {
+	if r.settings.Logger != nil {
+		r.settings.Logger.Log(level, msg, keyvals...)
+	}
+}
@@ -0,0 +1,538 @@
+package Replication
+
+import (
+	"fmt"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//the operations of the databases created by ChanDB the tests use
+type testDatabase interface {
+	ChanDB.Database
+	WriteBatch(payloads []string) error
+	ReadAckStream() ChanDB.AckStream
+	SetChangeListener(listener ChanDB.ChangeListener) error
+	SnapshotRecords(visit func(payload string) error) (uint64, error)
+}
+
+//opens a database in a temporary directory, the returned function closes and removes it
+func openTestDatabase(t *testing.T) (testDatabase, func()) {
+	dir, err := ioutil.TempDir("", "chandb-replication")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := ChanDB.Open(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+//counts the times all of the records are sent to a replica
+type countingSource struct {
+	Source
+	snapshots int64
+}
+
+func (s *countingSource) SnapshotRecords(visit func(payload string) error) (uint64, error) {
+	atomic.AddInt64(&s.snapshots, 1)
+	return s.Source.SnapshotRecords(visit)
+}
+
+func (s *countingSource) count() int64 {
+	return atomic.LoadInt64(&s.snapshots)
+}
+
+//starts a primary listening on a local port, the returned function closes it
+func startTestPrimary(t *testing.T, source Source, settings PrimarySettings) (*Primary, string, func()) {
+	primary, err := CreatePrimary(source, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- primary.Serve(listener)
+	}()
+
+	return primary, listener.Addr().String(), func() {
+		primary.Close()
+		if err := <-served; err != ErrServerClosed {
+			t.Errorf("Serve returned %v", err)
+		}
+	}
+}
+
+//the records of the database in order, without removing them
+func contents(t *testing.T, db testDatabase) string {
+	records := []string{}
+	_, err := db.SnapshotRecords(func(payload string) error {
+		records = append(records, payload)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fmt.Sprint(records)
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for condition() == false {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+//the replica has applied every change of the primary and the primary knows it
+func caughtUp(primary *Primary, replica *Replica) func() bool {
+	return func() bool {
+		replicaStats := replica.Stats()
+		primaryStats := primary.Stats()
+		if replicaStats.Connected == false || replicaStats.Syncing || replicaStats.Applied != primaryStats.Sequence {
+			return false
+		}
+
+		for _, lag := range primaryStats.Replicas {
+			if lag.Syncing || lag.Applied != primaryStats.Sequence {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+//forwards connections to the target, the connections can be cut or can stop forwarding without being closed
+type testProxy struct {
+	listener net.Listener
+	target   string
+	lock     *sync.Mutex
+	links    []*proxyLink
+}
+
+type proxyLink struct {
+	client net.Conn
+	server net.Conn
+	hung   int32
+}
+
+func startTestProxy(t *testing.T, target string) *testProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := &testProxy{listener: listener, target: target, lock: &sync.Mutex{}}
+	go proxy.accept()
+
+	return proxy
+}
+
+func (p *testProxy) address() string {
+	return p.listener.Addr().String()
+}
+
+func (p *testProxy) accept() {
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		server, err := net.Dial("tcp", p.target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+
+		link := &proxyLink{client: client, server: server}
+		p.lock.Lock()
+		p.links = append(p.links, link)
+		p.lock.Unlock()
+
+		go link.forward(client, server)
+		go link.forward(server, client)
+	}
+}
+
+//copies the data until either connection is closed, the data is dropped once the link hangs
+func (l *proxyLink) forward(to net.Conn, from net.Conn) {
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := from.Read(buffer)
+		if err != nil {
+			to.Close()
+			return
+		}
+
+		if atomic.LoadInt32(&l.hung) == 1 {
+			continue
+		}
+
+		_, err = to.Write(buffer[:n])
+		if err != nil {
+			from.Close()
+			return
+		}
+	}
+}
+
+//closes the current connections
+func (p *testProxy) cut() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, link := range p.links {
+		link.client.Close()
+		link.server.Close()
+	}
+	p.links = nil
+}
+
+//the current connections stay open and stop forwarding, as if the other end had gone away
+func (p *testProxy) hang() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, link := range p.links {
+		atomic.StoreInt32(&link.hung, 1)
+	}
+	p.links = nil
+}
+
+func (p *testProxy) close() {
+	p.listener.Close()
+	p.cut()
+}
+
+func TestReplicaReconnectsAfterSilence(t *testing.T) {
+	primaryDB, closePrimaryDB := openTestDatabase(t)
+	defer closePrimaryDB()
+	replicaDB, closeReplicaDB := openTestDatabase(t)
+	defer closeReplicaDB()
+
+	primary, address, closePrimary := startTestPrimary(t, primaryDB, PrimarySettings{HeartbeatInterval: 20 * time.Millisecond})
+	defer closePrimary()
+	proxy := startTestProxy(t, address)
+	defer proxy.close()
+
+	replica := CreateReplica(replicaDB, proxy.address(), ReplicaSettings{
+		ReconnectInterval: 20 * time.Millisecond,
+		Timeout:           200 * time.Millisecond,
+	})
+	defer replica.Close()
+
+	primaryDB.Write("a")
+	waitFor(t, "the replica to catch up", caughtUp(primary, replica))
+
+	proxy.hang()
+	waitFor(t, "the silent connection to time out", func() bool {
+		err, ok := replica.Stats().Err.(net.Error)
+		return ok && err.Timeout()
+	})
+
+	primaryDB.Write("b")
+	waitFor(t, "the replica to reconnect", func() bool {
+		return replica.Stats().Connected && replica.Stats().Applied == primary.Stats().Sequence
+	})
+	if got := contents(t, replicaDB); got != "[a b]" {
+		t.Fatalf("replica holds %s", got)
+	}
+}
+
+func TestReplicaResyncsWhenRecordsDiffer(t *testing.T) {
+	primaryDB, closePrimaryDB := openTestDatabase(t)
+	defer closePrimaryDB()
+	replicaDB, closeReplicaDB := openTestDatabase(t)
+	defer closeReplicaDB()
+
+	source := &countingSource{Source: primaryDB}
+	primary, address, closePrimary := startTestPrimary(t, source, PrimarySettings{HeartbeatInterval: 20 * time.Millisecond})
+	defer closePrimary()
+
+	primaryDB.WriteBatch([]string{"a", "b", "c"})
+
+	replica := CreateReplica(replicaDB, address, ReplicaSettings{ReconnectInterval: 20 * time.Millisecond})
+	defer replica.Close()
+	waitFor(t, "the replica to catch up", caughtUp(primary, replica))
+
+	//the replica is changed behind the back of the replication, it can not consume as many records as the primary
+	replicaDB.Read()
+	replicaDB.Read()
+	primaryDB.Read()
+	primaryDB.Read()
+	primaryDB.Write("d")
+
+	waitFor(t, "the records to be sent again", func() bool {
+		return source.count() == 2 && caughtUp(primary, replica)()
+	})
+	if got := contents(t, replicaDB); got != "[c d]" {
+		t.Fatalf("replica holds %s", got)
+	}
+}
+
+func TestHeldRecordIsMovedToTheEnd(t *testing.T) {
+	primaryDB, closePrimaryDB := openTestDatabase(t)
+	defer closePrimaryDB()
+	replicaDB, closeReplicaDB := openTestDatabase(t)
+	defer closeReplicaDB()
+
+	primary, address, closePrimary := startTestPrimary(t, primaryDB, PrimarySettings{HeartbeatInterval: 20 * time.Millisecond})
+	defer closePrimary()
+
+	replica := CreateReplica(replicaDB, address, ReplicaSettings{ReconnectInterval: 20 * time.Millisecond})
+	defer replica.Close()
+
+	primaryDB.WriteBatch([]string{"a", "b", "c"})
+
+	stream := primaryDB.ReadAckStream()
+	delivery := <-stream.Stream()
+	if delivery.Payload != "a" {
+		t.Fatalf("received %q", delivery.Payload)
+	}
+	stream.Close()
+
+	//the replicas consume from the beginning and write to the end, the record can not be restored in place. The
+	//stream may have read b ahead, it is moved to the end as well then
+	waitFor(t, "the replica to catch up", caughtUp(primary, replica))
+	got := contents(t, primaryDB)
+	if got != "[b c a]" && got != "[c a b]" {
+		t.Fatalf("primary holds %s", got)
+	}
+	if replicated := contents(t, replicaDB); replicated != got {
+		t.Fatalf("replica holds %s, primary holds %s", replicated, got)
+	}
+}
+
+//blocks writing the records while the lock is held by the test
+type gatedTarget struct {
+	Target
+	lock *sync.Mutex
+}
+
+func (t *gatedTarget) WriteBatch(payloads []string) error {
+	t.lock.Lock()
+	t.lock.Unlock()
+
+	return t.Target.WriteBatch(payloads)
+}
+
+func TestReplicationSyncsAndAppliesChanges(t *testing.T) {
+	primaryDB, closePrimaryDB := openTestDatabase(t)
+	defer closePrimaryDB()
+	replicaDB, closeReplicaDB := openTestDatabase(t)
+	defer closeReplicaDB()
+
+	primaryDB.WriteBatch([]string{"a", "b", "c"})
+	replicaDB.Write("stale")
+
+	primary, address, closePrimary := startTestPrimary(t, primaryDB, PrimarySettings{HeartbeatInterval: 20 * time.Millisecond})
+	defer closePrimary()
+	replica := CreateReplica(replicaDB, address, ReplicaSettings{ReconnectInterval: 20 * time.Millisecond})
+	defer replica.Close()
+
+	waitFor(t, "the records to be received", caughtUp(primary, replica))
+	if got := contents(t, replicaDB); got != "[a b c]" {
+		t.Fatalf("replica holds %s after the records were received", got)
+	}
+
+	steps := []struct {
+		name   string
+		change func()
+		want   string
+	}{
+		{"write", func() { primaryDB.Write("d") }, "[a b c d]"},
+		{"write batch", func() { primaryDB.WriteBatch([]string{"e", "f"}) }, "[a b c d e f]"},
+		{"read", func() { primaryDB.Read() }, "[b c d e f]"},
+		{"stream", func() {
+			stream := primaryDB.ReadAckStream()
+			delivery := <-stream.Stream()
+			stream.Ack(delivery)
+			stream.Close()
+		}, ""},
+		{"truncate", func() { primaryDB.Truncate() }, "[]"},
+		{"write after truncate", func() { primaryDB.Write("g") }, "[g]"},
+	}
+
+	for _, step := range steps {
+		step.change()
+		waitFor(t, "the "+step.name+" to be applied", caughtUp(primary, replica))
+
+		want := step.want
+		if want == "" {
+			want = contents(t, primaryDB)
+		}
+		if got := contents(t, replicaDB); got != want {
+			t.Fatalf("replica holds %s after the %s, expected %s", got, step.name, want)
+		}
+	}
+}
+
+func TestReplicaResumesAfterReconnecting(t *testing.T) {
+	primaryDB, closePrimaryDB := openTestDatabase(t)
+	defer closePrimaryDB()
+	replicaDB, closeReplicaDB := openTestDatabase(t)
+	defer closeReplicaDB()
+
+	source := &countingSource{Source: primaryDB}
+	primary, address, closePrimary := startTestPrimary(t, source, PrimarySettings{
+		HeartbeatInterval: 20 * time.Millisecond,
+		LogSize:           10,
+	})
+	defer closePrimary()
+	proxy := startTestProxy(t, address)
+	defer proxy.close()
+
+	replica := CreateReplica(replicaDB, proxy.address(), ReplicaSettings{ReconnectInterval: 100 * time.Millisecond})
+	defer replica.Close()
+
+	primaryDB.Write("a")
+	waitFor(t, "the records to be received", caughtUp(primary, replica))
+
+	//the missed changes are kept, the replica continues from the last change it applied
+	proxy.cut()
+	for i := 0; i < 5; i++ {
+		primaryDB.Write(fmt.Sprint(i))
+	}
+	primaryDB.Read()
+
+	waitFor(t, "the replica to resume", caughtUp(primary, replica))
+	if source.count() != 1 {
+		t.Fatalf("the records were sent %d times to a replica that could resume", source.count())
+	}
+	if got := contents(t, replicaDB); got != "[0 1 2 3 4]" {
+		t.Fatalf("replica holds %s after resuming", got)
+	}
+
+	//the replica is further behind than the kept changes, it is sent all of the records again
+	proxy.cut()
+	for i := 5; i < 20; i++ {
+		primaryDB.Write(fmt.Sprint(i))
+	}
+
+	waitFor(t, "the records to be sent again", func() bool {
+		return source.count() == 2 && caughtUp(primary, replica)()
+	})
+	if got, want := contents(t, replicaDB), contents(t, primaryDB); got != want {
+		t.Fatalf("replica holds %s, primary holds %s", got, want)
+	}
+}
+
+func TestReplicationLag(t *testing.T) {
+	primaryDB, closePrimaryDB := openTestDatabase(t)
+	defer closePrimaryDB()
+	replicaDB, closeReplicaDB := openTestDatabase(t)
+	defer closeReplicaDB()
+
+	primaryDB.WriteBatch([]string{"a", "b", "c"})
+
+	primary, address, closePrimary := startTestPrimary(t, primaryDB, PrimarySettings{HeartbeatInterval: 20 * time.Millisecond})
+	defer closePrimary()
+
+	//the records are received while writing them is blocked
+	target := &gatedTarget{Target: replicaDB, lock: &sync.Mutex{}}
+	target.lock.Lock()
+	replica := CreateReplica(target, address, ReplicaSettings{ReconnectInterval: 20 * time.Millisecond})
+	defer replica.Close()
+
+	waitFor(t, "the replica to receive the records", func() bool {
+		stats := replica.Stats()
+		return stats.Syncing && stats.PrimarySequence == primary.Stats().Sequence
+	})
+	if stats := replica.Stats(); stats.Applied != 0 || stats.Lag != stats.PrimarySequence {
+		t.Fatalf("syncing replica reports %+v", stats)
+	}
+	if replicas := primary.Stats().Replicas; len(replicas) != 1 || replicas[0].Syncing == false {
+		t.Fatalf("primary reports %+v for a syncing replica", replicas)
+	}
+
+	target.lock.Unlock()
+	waitFor(t, "the replica to catch up", caughtUp(primary, replica))
+	if stats := replica.Stats(); stats.Lag != 0 || stats.LastContact.IsZero() {
+		t.Fatalf("replica reports %+v after catching up", stats)
+	}
+
+	//the replica is blocked applying the first change, the others wait for it
+	target.lock.Lock()
+	primaryDB.Write("d")
+	primaryDB.Write("e")
+	primaryDB.Write("f")
+
+	waitFor(t, "the primary to report the lag", func() bool {
+		replicas := primary.Stats().Replicas
+		return len(replicas) == 1 && replicas[0].Lag == 3 && replicas[0].LagTime > 0
+	})
+
+	target.lock.Unlock()
+	waitFor(t, "the replica to catch up", caughtUp(primary, replica))
+
+	stats := primary.Stats()
+	if stats.Replicas[0].Lag != 0 || stats.Replicas[0].LagTime != 0 || stats.Replicas[0].Applied != stats.Sequence {
+		t.Fatalf("primary reports %+v after the replica caught up", stats.Replicas[0])
+	}
+	if stats := replica.Stats(); stats.Lag != 0 || stats.Applied != stats.PrimarySequence {
+		t.Fatalf("replica reports %+v after catching up", stats)
+	}
+}
+
+func TestPromote(t *testing.T) {
+	primaryDB, closePrimaryDB := openTestDatabase(t)
+	defer closePrimaryDB()
+	replicaDB, closeReplicaDB := openTestDatabase(t)
+	defer closeReplicaDB()
+
+	primary, address, closePrimary := startTestPrimary(t, primaryDB, PrimarySettings{HeartbeatInterval: 20 * time.Millisecond})
+	defer closePrimary()
+	replica := CreateReplica(replicaDB, address, ReplicaSettings{ReconnectInterval: 20 * time.Millisecond})
+	defer replica.Close()
+
+	primaryDB.WriteBatch([]string{"a", "b"})
+	waitFor(t, "the replica to catch up", caughtUp(primary, replica))
+
+	err := replica.Promote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replica.Promote(); err != ErrPromoted {
+		t.Fatalf("second Promote() returned %v", err)
+	}
+	if stats := replica.Stats(); stats.Promoted == false || stats.Connected {
+		t.Fatalf("promoted replica reports %+v", stats)
+	}
+
+	//the changes of the old primary are not applied anymore, the database is written and read as any other
+	primaryDB.Write("ignored")
+	err = replicaDB.Write("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := replicaDB.Read()
+	if err != nil || payload != "a" {
+		t.Fatalf("read %q, %v", payload, err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := contents(t, replicaDB); got != "[b c]" {
+		t.Fatalf("promoted replica holds %s", got)
+	}
+}
@@ -0,0 +1,239 @@
+package Replication
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/theorx/ChanDB/internal/Protocol"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+)
+
+var errReplicaBehind = errors.New("the changes the replica is missing are not kept anymore")
+
+//connection of a replica to the primary
+type replicaConnection struct {
+	primary *Primary
+	conn    net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	//guarded by the lock of the primary
+	syncing bool
+	applied uint64
+}
+
+func createReplicaConnection(primary *Primary, conn net.Conn) *replicaConnection {
+	return &replicaConnection{
+		primary: primary,
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		writer:  bufio.NewWriter(conn),
+	}
+}
+
+func (r *replicaConnection) serve(ctx context.Context) error {
+	r.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+
+	op, body, err := Protocol.ReadFrame(r.reader)
+	if err != nil {
+		return err
+	}
+
+	if op != opReplicate {
+		Protocol.WriteFrame(r.writer, statusError, []byte("expected a replicate frame"))
+		return fmt.Errorf("unexpected operation %d", op)
+	}
+
+	epoch, sequence, err := decodePosition(body)
+	if err != nil {
+		return err
+	}
+
+	r.conn.SetReadDeadline(time.Time{})
+
+	if r.primary.canResume(epoch, sequence) {
+		r.primary.setApplied(r, sequence, false)
+		err = Protocol.WriteFrame(r.writer, statusResume, encodePosition(r.primary.epoch, sequence))
+	} else {
+		r.primary.setApplied(r, 0, true)
+		sequence, err = r.sendSnapshot()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	//the replica reports the changes it has applied, the connection ends when it disconnects
+	var receiveErr error
+	receiving := make(chan bool)
+	go func() {
+		defer close(receiving)
+		defer cancel()
+
+		receiveErr = r.receive()
+	}()
+
+	err = r.sendChanges(ctx, sequence)
+
+	r.conn.Close()
+	<-receiving
+
+	if err == nil {
+		err = receiveErr
+	}
+
+	return err
+}
+
+/**
+The records are copied to a temporary file while reading is locked, they are sent to the replica from the
+file so the database is not locked while the replica receives them. Returns the sequence the records include
+*/
+func (r *replicaConnection) sendSnapshot() (uint64, error) {
+	file, err := ioutil.TempFile(r.primary.settings.TempDir, "chandb-snapshot-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	sequence, err := r.primary.source.SnapshotRecords(func(payload string) error {
+		_, err := writer.Write(Protocol.EncodeStrings([]string{payload}))
+		return err
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	err = Protocol.WriteFrame(r.writer, statusSnapshot, encodePosition(r.primary.epoch, sequence))
+	if err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReader(file)
+	batch := make([]byte, 0, batchBytes)
+	for {
+		record, err := readEncodedString(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if len(batch) > 0 && len(batch)+len(record) > batchBytes {
+			err = Protocol.WriteFrame(r.writer, statusRecords, batch)
+			if err != nil {
+				return 0, err
+			}
+			batch = batch[:0]
+		}
+
+		batch = append(batch, record...)
+	}
+
+	if len(batch) > 0 {
+		err = Protocol.WriteFrame(r.writer, statusRecords, batch)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return sequence, Protocol.WriteFrame(r.writer, statusSnapshotEnd, nil)
+}
+
+//reads one string written by Protocol.EncodeStrings() and returns it as it was encoded
+func readEncodedString(reader *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := make([]byte, 4+binary.BigEndian.Uint32(header))
+	copy(encoded, header)
+
+	_, err = io.ReadFull(reader, encoded[4:])
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return encoded, err
+}
+
+//sends the changes following the sequence until the context is done
+func (r *replicaConnection) sendChanges(ctx context.Context, sequence uint64) error {
+	heartbeat := time.NewTicker(r.primary.settings.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		changes, changed, err := r.primary.changesAfter(sequence)
+		if err != nil {
+			return err
+		}
+
+		if len(changes) > 0 {
+			body := make([]byte, 0)
+			for _, change := range changes {
+				body = appendChange(body, change)
+			}
+
+			err = Protocol.WriteFrame(r.writer, statusChanges, body)
+			if err != nil {
+				return err
+			}
+
+			sequence = changes[len(changes)-1].Sequence
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		case <-heartbeat.C:
+			err = Protocol.WriteFrame(r.writer, statusHeartbeat, encodeSequence(r.primary.sequence()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+//reads the sequences the replica has applied until the connection fails
+func (r *replicaConnection) receive() error {
+	for {
+		op, body, err := Protocol.ReadFrame(r.reader)
+		if err != nil {
+			return err
+		}
+
+		if op != opApplied {
+			return fmt.Errorf("unexpected operation %d", op)
+		}
+
+		sequence, err := decodeSequence(body)
+		if err != nil {
+			return err
+		}
+
+		r.primary.setApplied(r, sequence, false)
+	}
+}
@@ -0,0 +1,175 @@
+package Server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"io/ioutil"
+	"net/http"
+)
+
+func (s *Server) listQueues(w http.ResponseWriter, r *http.Request) {
+	queues, err := s.registry.ListQueues()
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	if queues == nil {
+		queues = []string{}
+	}
+
+	writeJSON(w, http.StatusOK, queueList{Queues: queues})
+}
+
+func (s *Server) enqueue(w http.ResponseWriter, r *http.Request, name string) {
+	body, err := s.readBody(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.write(w, name, []string{string(body)})
+}
+
+func (s *Server) enqueueBatch(w http.ResponseWriter, r *http.Request, name string) {
+	body, err := s.readBody(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payloads []string
+	err = json.Unmarshal(body, &payloads)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidBatch)
+		return
+	}
+
+	s.write(w, name, payloads)
+}
+
+func (s *Server) write(w http.ResponseWriter, name string, payloads []string) {
+	queue, err := s.createQueue(name)
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	err = queue.WriteBatch(payloads)
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/**
+The record is removed from the queue before the response is written, it is lost when the client goes away
+before receiving it
+*/
+func (s *Server) dequeue(w http.ResponseWriter, r *http.Request, name string) {
+	wait, err := s.waitTime(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	queue, err := s.existingQueue(name)
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	record, err := queue.ReadContext(ctx)
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	writeRecord(w, record)
+}
+
+func (s *Server) peek(w http.ResponseWriter, r *http.Request, name string) {
+	queue, err := s.existingQueue(name)
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	record, err := queue.Peek()
+	if errors.Is(err, ChanDB.ErrEmpty) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	writeRecord(w, record)
+}
+
+func (s *Server) stats(w http.ResponseWriter, r *http.Request, name string) {
+	queue, err := s.existingQueue(name)
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, queue.Stats())
+}
+
+func (s *Server) truncate(w http.ResponseWriter, r *http.Request, name string) {
+	queue, err := s.existingQueue(name)
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	err = queue.Truncate()
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) compact(w http.ResponseWriter, r *http.Request, name string) {
+	queue, err := s.existingQueue(name)
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	err = queue.Compact()
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//reads the whole request body, fails when it is longer than MaxBodyBytes
+func (s *Server) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, s.settings.MaxBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidBody, err)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,167 @@
+package Server
+
+import (
+	"encoding/json"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+//creates a server for a registry in a temporary directory, the returned function closes the registry
+func createTestServer(t *testing.T, settings Settings) (*Server, func()) {
+	dir, err := ioutil.TempDir("", "chandb-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := ChanDB.CreateRegistry(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return CreateServer(registry, settings), func() {
+		registry.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func request(server *Server, method string, target string, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	return recorder
+}
+
+func expectResponse(t *testing.T, response *httptest.ResponseRecorder, status int, body string) {
+	t.Helper()
+
+	if response.Code != status || response.Body.String() != body {
+		t.Fatalf("response is %d %q, expected %d %q", response.Code, response.Body.String(), status, body)
+	}
+}
+
+func TestEnqueueAndDequeue(t *testing.T) {
+	server, cleanup := createTestServer(t, Settings{})
+	defer cleanup()
+
+	expectResponse(t, request(server, http.MethodGet, "/queues", ""), http.StatusOK, "{\"queues\":[]}\n")
+	expectResponse(t, request(server, http.MethodPost, "/queues/orders/messages", "a"), http.StatusNoContent, "")
+	expectResponse(t, request(server, http.MethodPost, "/queues/orders/messages/batch", `["b","c"]`), http.StatusNoContent, "")
+	expectResponse(t, request(server, http.MethodGet, "/queues", ""), http.StatusOK, "{\"queues\":[\"orders\"]}\n")
+
+	//peek does not remove the record
+	expectResponse(t, request(server, http.MethodGet, "/queues/orders/peek", ""), http.StatusOK, "a")
+
+	response := request(server, http.MethodGet, "/queues/orders/messages", "")
+	expectResponse(t, response, http.StatusOK, "a")
+	if contentType := response.Header().Get("Content-Type"); contentType != "application/octet-stream" {
+		t.Fatalf("record is sent as %s", contentType)
+	}
+
+	response = request(server, http.MethodGet, "/queues/orders/stats", "")
+	stats := ChanDB.Stats{}
+	if response.Code != http.StatusOK || json.Unmarshal(response.Body.Bytes(), &stats) != nil || stats.Records != 2 {
+		t.Fatalf("stats response is %d %q", response.Code, response.Body.String())
+	}
+
+	expectResponse(t, request(server, http.MethodPost, "/queues/orders/compact", ""), http.StatusNoContent, "")
+	expectResponse(t, request(server, http.MethodGet, "/queues/orders/messages", ""), http.StatusOK, "b")
+	expectResponse(t, request(server, http.MethodPost, "/queues/orders/truncate", ""), http.StatusNoContent, "")
+
+	//an empty queue is not an error
+	expectResponse(t, request(server, http.MethodGet, "/queues/orders/messages", ""), http.StatusNoContent, "")
+	expectResponse(t, request(server, http.MethodGet, "/queues/orders/peek", ""), http.StatusNoContent, "")
+
+	started := time.Now()
+	expectResponse(t, request(server, http.MethodGet, "/queues/orders/messages?wait=50ms", ""), http.StatusNoContent, "")
+	if waited := time.Since(started); waited < 50*time.Millisecond {
+		t.Fatalf("the request waited %v for a record, expected 50ms", waited)
+	}
+}
+
+func TestDequeueWaitsForRecord(t *testing.T) {
+	server, cleanup := createTestServer(t, Settings{})
+	defer cleanup()
+
+	expectResponse(t, request(server, http.MethodPost, "/queues/orders/truncate", ""), http.StatusNotFound, "{\"error\":\"queue does not exist\"}\n")
+	expectResponse(t, request(server, http.MethodPost, "/queues/orders/messages/batch", "[]"), http.StatusNoContent, "")
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		request(server, http.MethodPost, "/queues/orders/messages", "late")
+	}()
+
+	expectResponse(t, request(server, http.MethodGet, "/queues/orders/messages?wait=5", ""), http.StatusOK, "late")
+}
+
+func TestHandlerErrors(t *testing.T) {
+	server, cleanup := createTestServer(t, Settings{MaxBodyBytes: 4})
+	defer cleanup()
+
+	cases := []struct {
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "/", "", http.StatusNotFound},
+		{http.MethodGet, "/queues/orders/unknown", "", http.StatusNotFound},
+		{http.MethodGet, "/queues/missing/messages", "", http.StatusNotFound},
+		{http.MethodGet, "/queues/missing/peek", "", http.StatusNotFound},
+		{http.MethodGet, "/queues/missing/stats", "", http.StatusNotFound},
+		{http.MethodPost, "/queues/missing/compact", "", http.StatusNotFound},
+		{http.MethodPost, "/queues/bad$name/messages", "a", http.StatusBadRequest},
+		{http.MethodPost, "/queues/orders/messages", "too long", http.StatusBadRequest},
+		{http.MethodPost, "/queues/orders/messages/batch", `{"a":1}`, http.StatusBadRequest},
+		{http.MethodPost, "/queues/orders/messages/batch", `[1]`, http.StatusBadRequest},
+		{http.MethodGet, "/queues/orders/messages?wait=soon", "", http.StatusBadRequest},
+		{http.MethodGet, "/queues/orders/messages?wait=-1s", "", http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		response := request(server, c.method, c.target, c.body)
+
+		errorBody := errorResponse{}
+		if response.Code != c.status || json.Unmarshal(response.Body.Bytes(), &errorBody) != nil || errorBody.Error == "" {
+			t.Fatalf("%s %s responded %d %q, expected %d with an error", c.method, c.target, response.Code, response.Body.String(), c.status)
+		}
+	}
+}
+
+func TestHandlerMethods(t *testing.T) {
+	server, cleanup := createTestServer(t, Settings{})
+	defer cleanup()
+
+	cases := []struct {
+		method string
+		target string
+		allow  string
+	}{
+		{http.MethodPost, "/queues", "GET"},
+		{http.MethodDelete, "/queues/orders/messages", "GET, POST"},
+		{http.MethodGet, "/queues/orders/messages/batch", "POST"},
+		{http.MethodPost, "/queues/orders/peek", "GET"},
+		{http.MethodPost, "/queues/orders/stream", "GET"},
+		{http.MethodPut, "/queues/orders/stats", "GET"},
+		{http.MethodGet, "/queues/orders/truncate", "POST"},
+		{http.MethodGet, "/queues/orders/compact", "POST"},
+	}
+
+	for _, c := range cases {
+		response := request(server, c.method, c.target, "")
+		expectResponse(t, response, http.StatusMethodNotAllowed, "{\"error\":\"method not allowed\"}\n")
+
+		if allow := response.Header().Get("Allow"); allow != c.allow {
+			t.Fatalf("%s %s allows %q, expected %q", c.method, c.target, allow, c.allow)
+		}
+	}
+
+	//the queues are not created by the refused requests
+	expectResponse(t, request(server, http.MethodGet, "/queues", ""), http.StatusOK, "{\"queues\":[]}\n")
+}
@@ -0,0 +1,66 @@
+package Server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"net/http"
+)
+
+var (
+	errNotFound         = errors.New("not found")
+	errMethodNotAllowed = errors.New("method not allowed")
+	errInvalidWait      = errors.New("invalid wait time, expected a duration such as 5s or 500ms")
+	errInvalidBody      = errors.New("invalid request body")
+	errInvalidBatch     = errors.New("invalid batch, expected a JSON array of strings")
+	errInvalidFormat    = errors.New("invalid stream format, expected events or lines")
+	//the response writer cannot flush the records to the client
+	errStreamingUnsupported = errors.New("streaming is not supported")
+)
+
+type queueList struct {
+	Queues []string `json:"queues"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+//records are written as they are stored, without any encoding
+func writeRecord(w http.ResponseWriter, record string) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(record))
+}
+
+//responds with the status matching the error returned by the registry or the queue
+func writeQueueError(w http.ResponseWriter, err error) {
+	writeError(w, errorStatus(err), err)
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ChanDB.ErrInvalidQueueName), errors.Is(err, ChanDB.ErrInvalidRecord):
+		return http.StatusBadRequest
+	case errors.Is(err, ChanDB.ErrQueueNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ChanDB.ErrReadOnly):
+		return http.StatusForbidden
+	case errors.Is(err, ChanDB.ErrQueueFull):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, ChanDB.ErrClosed):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
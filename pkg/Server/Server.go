@@ -0,0 +1,200 @@
+package Server
+
+import (
+	"context"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Settings struct {
+	/**
+	Longest time a dequeue request waits for a record, longer waits requested by the clients are shortened.
+	Default is 30 seconds
+	*/
+	MaxWait time.Duration
+	/**
+	Largest request body accepted by the enqueue endpoints, default is 16MiB
+	*/
+	MaxBodyBytes int64
+	/**
+	Interval of the heartbeat comments sent to the idle Server-Sent Events streams, default is 15 seconds
+	*/
+	HeartbeatInterval time.Duration
+}
+
+const (
+	defaultMaxWait           = 30 * time.Second
+	defaultMaxBodyBytes      = 16 << 20
+	defaultHeartbeatInterval = 15 * time.Second
+)
+
+/**
+Operations of the queues used by the server, the queues opened by ChanDB.Registry implement it
+*/
+type Queue interface {
+	WriteBatch(payloads []string) error
+	ReadContext(ctx context.Context) (string, error)
+	Peek() (string, error)
+	Truncate() error
+	Compact() error
+	Stats() ChanDB.Stats
+	ReadStream() ChanDB.Stream
+}
+
+/**
+Server is an http.Handler exposing the queues of a registry over HTTP:
+
+	GET  /queues                          names of the queues
+	POST /queues/{name}/messages          enqueues the request body as one record, the queue is created if needed
+	POST /queues/{name}/messages/batch    enqueues a JSON array of strings in the given order
+	GET  /queues/{name}/messages?wait=5s  dequeues one record, waits up to the given time for a record to arrive
+	GET  /queues/{name}/peek              returns the next record without removing it
+	GET  /queues/{name}/stream            sends the records as Server-Sent Events, ?format=lines sends one per line
+	GET  /queues/{name}/stats             statistics of the queue as JSON
+	POST /queues/{name}/truncate          removes all of the records
+	POST /queues/{name}/compact           runs garbage collection now
+
+The dequeue and peek endpoints respond with 204 No Content when the queue is empty. A batch is not written
+atomically, when one of its records fails, for example because the queue is full, the records before it have been
+stored
+*/
+type Server struct {
+	registry *ChanDB.Registry
+	settings Settings
+}
+
+func CreateServer(registry *ChanDB.Registry, settings Settings) *Server {
+	if settings.MaxWait <= 0 {
+		settings.MaxWait = defaultMaxWait
+	}
+
+	if settings.MaxBodyBytes <= 0 {
+		settings.MaxBodyBytes = defaultMaxBodyBytes
+	}
+
+	if settings.HeartbeatInterval <= 0 {
+		settings.HeartbeatInterval = defaultHeartbeatInterval
+	}
+
+	return &Server{
+		registry: registry,
+		settings: settings,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if len(path) == 0 || path[0] != "queues" {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	if len(path) == 1 {
+		if allowMethod(w, r, http.MethodGet) {
+			s.listQueues(w, r)
+		}
+		return
+	}
+
+	name := path[1]
+	action := strings.Join(path[2:], "/")
+
+	switch action {
+	case "messages":
+		if r.Method == http.MethodPost {
+			s.enqueue(w, r, name)
+		} else if allowMethod(w, r, http.MethodGet, http.MethodPost) {
+			s.dequeue(w, r, name)
+		}
+	case "messages/batch":
+		if allowMethod(w, r, http.MethodPost) {
+			s.enqueueBatch(w, r, name)
+		}
+	case "peek":
+		if allowMethod(w, r, http.MethodGet) {
+			s.peek(w, r, name)
+		}
+	case "stream":
+		if allowMethod(w, r, http.MethodGet) {
+			s.stream(w, r, name)
+		}
+	case "stats":
+		if allowMethod(w, r, http.MethodGet) {
+			s.stats(w, r, name)
+		}
+	case "truncate":
+		if allowMethod(w, r, http.MethodPost) {
+			s.truncate(w, r, name)
+		}
+	case "compact":
+		if allowMethod(w, r, http.MethodPost) {
+			s.compact(w, r, name)
+		}
+	default:
+		writeError(w, http.StatusNotFound, errNotFound)
+	}
+}
+
+//responds with 405 Method Not Allowed when the method is not one of the allowed ones
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+
+	return false
+}
+
+//opens the queue, the queue is created when it does not exist
+func (s *Server) createQueue(name string) (Queue, error) {
+	queue, err := s.registry.OpenQueue(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return queue, nil
+}
+
+//opens the queue, fails with ChanDB.ErrQueueNotFound when it does not exist
+func (s *Server) existingQueue(name string) (Queue, error) {
+	exists, err := s.registry.HasQueue(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists == false {
+		return nil, ChanDB.ErrQueueNotFound
+	}
+
+	return s.createQueue(name)
+}
+
+//wait time requested by the client, as a duration (1500ms) or as seconds (1.5), limited to MaxWait
+func (s *Server) waitTime(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("wait")
+	if value == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		wait, err = time.ParseDuration(value + "s")
+	}
+
+	if err != nil || wait < 0 {
+		return 0, errInvalidWait
+	}
+
+	if wait > s.settings.MaxWait {
+		wait = s.settings.MaxWait
+	}
+
+	return wait, nil
+}
@@ -0,0 +1,119 @@
+package Server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	//records are sent as Server-Sent Events: "data: record\n\n", with one data field per line of the record
+	streamFormatEvents = "events"
+	//records are sent one per line in a chunked response, the line breaks of the records are escaped
+	streamFormatLines = "lines"
+)
+
+//the line breaks of the Server-Sent Events, a record is split into data fields at them
+var eventLineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+//the backslashes are escaped as well, so the escaped records can be told apart from the line breaks
+var lineEscapes = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+
+/**
+Sends the records of the queue to the client as they arrive until the client disconnects or the registry is
+closed. The connection is mapped to a ChanDB Stream, so a record is taken from the queue only once the previous
+one has been written to the connection, and the record held by the stream is restored to the queue when the
+client disconnects. A record that has been written to the connection when the client goes away is lost
+*/
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, name string) {
+	flusher, ok := w.(http.Flusher)
+	if ok == false {
+		writeError(w, http.StatusInternalServerError, errStreamingUnsupported)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = streamFormatEvents
+	}
+
+	if format != streamFormatEvents && format != streamFormatLines {
+		writeError(w, http.StatusBadRequest, errInvalidFormat)
+		return
+	}
+
+	queue, err := s.existingQueue(name)
+	if err != nil {
+		writeQueueError(w, err)
+		return
+	}
+
+	stream := queue.ReadStream()
+	defer stream.Close()
+
+	if format == streamFormatEvents {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	//asks nginx not to buffer the response
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	//the comments keep the idle connections open through proxies, the line format has no comments
+	heartbeat := time.NewTicker(s.settings.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if format == streamFormatEvents {
+				_, err = w.Write([]byte(": heartbeat\n\n"))
+			}
+		case record, ok := <-stream.Stream():
+			//the registry has been closed
+			if ok == false {
+				return
+			}
+
+			if format == streamFormatEvents {
+				_, err = w.Write([]byte(formatEvent(record)))
+			} else {
+				_, err = w.Write([]byte(formatLine(record)))
+			}
+		}
+
+		if err != nil {
+			return
+		}
+
+		flusher.Flush()
+	}
+}
+
+/**
+Every line of the record is sent as a data field of one event, the clients join the fields with "\n". A record
+containing "\r" is received with "\n" in its place
+*/
+func formatEvent(record string) string {
+	lines := strings.Split(eventLineBreaks.Replace(record), "\n")
+
+	event := &strings.Builder{}
+	for _, line := range lines {
+		event.WriteString("data: ")
+		event.WriteString(line)
+		event.WriteString("\n")
+	}
+	event.WriteString("\n")
+
+	return event.String()
+}
+
+//"\\", "\n" and "\r" are escaped with a backslash, so every record takes one line
+func formatLine(record string) string {
+	return lineEscapes.Replace(record) + "\n"
+}
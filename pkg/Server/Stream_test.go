@@ -0,0 +1,103 @@
+package Server
+
+import (
+	"bufio"
+	"github.com/theorx/ChanDB/pkg/ChanDB"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFormatEvent(t *testing.T) {
+	cases := map[string]string{
+		"record":          "data: record\n\n",
+		"":                "data: \n\n",
+		"a\nb":            "data: a\ndata: b\n\n",
+		"a\r\nb\rc":       "data: a\ndata: b\ndata: c\n\n",
+		"a\n\ndata: fake": "data: a\ndata: \ndata: data: fake\n\n",
+	}
+
+	for record, expected := range cases {
+		if event := formatEvent(record); event != expected {
+			t.Fatalf("%q is sent as %q, expected %q", record, event, expected)
+		}
+	}
+}
+
+func TestFormatLine(t *testing.T) {
+	cases := map[string]string{
+		"record":     "record\n",
+		"a\nb":       "a\\nb\n",
+		"a\r\nb":     "a\\r\\nb\n",
+		"C:\\dir\\n": "C:\\\\dir\\\\n\n",
+	}
+
+	for record, expected := range cases {
+		if line := formatLine(record); line != expected {
+			t.Fatalf("%q is sent as %q, expected %q", record, line, expected)
+		}
+	}
+}
+
+func TestStreamRecordsWithNewlines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chandb-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	//the compressed records can hold newlines
+	registry, err := ChanDB.CreateRegistry(dir, ChanDB.WithCompression(ChanDB.GzipCompressor{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+
+	queue, err := registry.OpenQueue("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = queue.WriteBatch([]string{"first\n\ndata: injected", "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(CreateServer(registry, Settings{}))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/queues/events/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	//the events are separated by empty lines, the data fields of an event are joined with newlines
+	reader := bufio.NewReader(response.Body)
+	events := make([]string, 0)
+	fields := make([]string, 0)
+	for len(events) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			events = append(events, strings.Join(fields, "\n"))
+			fields = fields[:0]
+			continue
+		}
+
+		if strings.HasPrefix(line, "data: ") {
+			fields = append(fields, strings.TrimPrefix(line, "data: "))
+		}
+	}
+
+	if events[0] != "first\n\ndata: injected" || events[1] != "second" {
+		t.Fatalf("received the events %q", events)
+	}
+}
@@ -0,0 +1,46 @@
+package Signal
+
+import "sync"
+
+/**
+Broadcast wakes up all of the goroutines waiting for it, while a Signal wakes up only one of them. Used for
+waking up the readers waiting for new records
+*/
+type Broadcast struct {
+	lock    *sync.Mutex
+	channel chan bool
+	waiting bool
+}
+
+func CreateBroadcast() *Broadcast {
+	return &Broadcast{
+		lock:    &sync.Mutex{},
+		channel: make(chan bool),
+	}
+}
+
+/**
+Returns a channel that is closed by the next Notify() call. The channel has to be taken before checking the
+condition that is waited for, so a Notify() made after the check is not missed
+*/
+func (b *Broadcast) Wait() <-chan bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.waiting = true
+	return b.channel
+}
+
+func (b *Broadcast) Notify() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	//nobody is waiting for the current channel, it can be reused
+	if b.waiting == false {
+		return
+	}
+
+	close(b.channel)
+	b.channel = make(chan bool)
+	b.waiting = false
+}